@@ -0,0 +1,358 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+var errPresenceUnsupported = errors.New("I can't check who is absent with this notifier")
+
+// messageTime returns the time messages are rendered with
+var messageTime = time.Now
+
+// actionFn performs the action for an intent and returns
+// the text spoken back to the user when ending the session
+type actionFn func(mc mqttClient, p model.Payload, ic model.IntentConfig) (string, error)
+
+// actions maps each intent action to its handler
+var actions = map[string]actionFn{
+	model.ActionPing:           pingAction(TargetAny),
+	model.ActionPingUser:       pingAction(TargetUser),
+	model.ActionPingChannel:    pingAction(TargetChannel),
+	model.ActionStandupMessage: standupMessageAction,
+	model.ActionListAbsent:     listAbsentAction,
+}
+
+// pingResult tracks the outcome of
+// messaging a single spoken name
+type pingResult struct {
+	name   string
+	target Target
+	err    error
+}
+
+// pendingMessage is a message held back until
+// the user confirms the resolved target
+type pendingMessage struct {
+	name   string
+	target Target
+	msg    model.RichMessage
+}
+
+// confirmationRequired is returned by actions when the intent or
+// slots were below the configured confidence, nothing pending
+// is sent until the user confirms the question asked
+type confirmationRequired struct {
+	question string
+	pending  []pendingMessage
+}
+
+func (c confirmationRequired) Error() string {
+	return c.question
+}
+
+// pingAction messages every target of kind named in the
+// payload slots with one of the configured messages
+func pingAction(kind TargetKind) actionFn {
+	return func(mc mqttClient, p model.Payload, _ model.IntentConfig) (string, error) {
+		sc := mc.config.SnipsConfig
+		slots := uniqueSlots(p, sc.SlotName, sc.ChannelSlot())
+
+		// We won't get here if slot is required
+		// but if not set to required we will
+		if len(slots) == 0 {
+			return "", errMissingSlots
+		}
+
+		var (
+			results []pingResult
+			pending []pendingMessage
+		)
+
+		for _, s := range slots {
+			name := s.Value.Value
+
+			// Values of a separate channel slot are only channels
+			kind := kind
+			if s.Name != sc.SlotName && kind == TargetAny {
+				kind = TargetChannel
+			}
+
+			if sc.IsIntentUncertain(p.Intent) || sc.IsSlotUncertain(s) {
+				t, err := mc.notifier.Resolve(name, kind)
+				if err != nil {
+					results = append(results, pingResult{name: name, err: err})
+					continue
+				}
+
+				msg, err := mc.pingMessage(p, t)
+				if err != nil {
+					results = append(results, pingResult{name: name, err: err})
+					continue
+				}
+
+				pending = append(pending, pendingMessage{name: name, target: t, msg: msg})
+				continue
+			}
+
+			r := mc.ping(p, name, kind)
+			if r.err != nil {
+				log.Printf("failed to message %q: %s\n", r.name, r.err)
+			}
+
+			results = append(results, r)
+		}
+
+		if len(pending) > 0 {
+			return "", confirmPending(results, pending)
+		}
+
+		// A single name speaks back the error
+		// as it's more helpful than a summary
+		if len(results) == 1 && results[0].err != nil {
+			return "", results[0].err
+		}
+
+		return pingSummary(results), nil
+	}
+}
+
+// confirmPending asks the user to confirm the pending
+// targets after summarising those already messaged
+func confirmPending(results []pingResult, pending []pendingMessage) confirmationRequired {
+	names := make([]string, 0, len(pending))
+	for _, pm := range pending {
+		names = append(names, pm.target.Name)
+	}
+
+	question := fmt.Sprintf("Did you mean %s?", joinNames(names))
+	if len(results) > 0 {
+		question = pingSummary(results) + ". " + question
+	}
+
+	return confirmationRequired{question: question, pending: pending}
+}
+
+// ping resolves the name and sends the
+// target one of the configured messages
+func (mc mqttClient) ping(p model.Payload, name string, kind TargetKind) pingResult {
+	r := pingResult{name: name}
+
+	r.target, r.err = mc.notifier.Resolve(name, kind)
+	if r.err != nil {
+		return r
+	}
+
+	return mc.pingTarget(p, r)
+}
+
+// pingTarget sends the resolved target of the
+// result one of the configured messages
+func (mc mqttClient) pingTarget(p model.Payload, r pingResult) pingResult {
+	var msg model.RichMessage
+	if msg, r.err = mc.pingMessage(p, r.target); r.err != nil {
+		return r
+	}
+
+	r.err = sendMessage(mc.notifier, r.target, msg)
+	return r
+}
+
+// pingMessage renders one of the configured plain or rich
+// messages at random for the target requested in the payload
+func (mc mqttClient) pingMessage(p model.Payload, t Target) (model.RichMessage, error) {
+	msgs, rich := mc.config.Messages(), mc.config.RichMessages()
+	d := messageData(p, t)
+
+	i := rand.Intn(len(msgs) + len(rich))
+	if i >= len(msgs) {
+		return model.RenderRichMessage(rich[i-len(msgs)], d)
+	}
+
+	text, err := model.RenderMessage(msgs[i], d)
+	return model.RichMessage{Text: text}, err
+}
+
+// messageData returns the context messages for
+// the target requested in the payload render with
+func messageData(p model.Payload, t Target) model.MessageData {
+	d := model.NewMessageData(messageTime())
+	d.Name = t.Name
+	d.FirstName = firstNonEmpty(t.FirstName, strings.SplitN(t.Name, " ", 2)[0])
+	d.RealName = firstNonEmpty(t.RealName, t.Name)
+	d.Mention = firstNonEmpty(t.Mention, t.Name)
+	d.Channel = t.Kind == TargetChannel || t.Kind == TargetUsergroup
+	d.Site = p.SiteID
+
+	return d
+}
+
+// uniqueSlots returns the payload slots with
+// one of the names with unique values in order
+func uniqueSlots(p model.Payload, names ...string) []model.Slot {
+	var slots []model.Slot
+	seen := map[string]bool{}
+
+	for _, s := range p.Slots {
+		v := s.Value.Value
+		if !containsString(names, s.Name) || v == "" || seen[v] {
+			continue
+		}
+
+		seen[v] = true
+		slots = append(slots, s)
+	}
+
+	return slots
+}
+
+// pingSummary builds the spoken reply of who was messaged
+// i.e "I've messaged Alice and Bob, couldn't find Carol"
+func pingSummary(results []pingResult) string {
+	var sent, missing, failed []string
+
+	for _, r := range results {
+		switch r.err.(type) {
+		case nil:
+			sent = append(sent, r.name)
+		case notFoundError:
+			missing = append(missing, r.name)
+		default:
+			failed = append(failed, r.name)
+		}
+	}
+
+	var parts []string
+	if len(sent) > 0 {
+		parts = append(parts, "I've messaged "+joinNames(sent))
+	}
+
+	if len(missing) > 0 {
+		parts = append(parts, "couldn't find "+joinNames(missing))
+	}
+
+	if len(failed) > 0 {
+		parts = append(parts, "couldn't message "+joinNames(failed))
+	}
+
+	summary := strings.Join(parts, ", ")
+	if len(sent) == 0 {
+		summary = "I " + summary
+	}
+
+	return summary
+}
+
+// standupMessageAction posts the intent's
+// canned message to the intent's channel
+func standupMessageAction(mc mqttClient, p model.Payload, ic model.IntentConfig) (string, error) {
+	t, err := mc.notifier.Resolve(ic.Channel, TargetChannel)
+	if err != nil {
+		return "", err
+	}
+
+	text, err := model.RenderMessage(ic.Message, messageData(p, t))
+	if err != nil {
+		return "", err
+	}
+
+	if mc.config.SnipsConfig.IsIntentUncertain(p.Intent) {
+		return "", confirmationRequired{
+			question: fmt.Sprintf("Did you want me to post the standup message to %s?", ic.Channel),
+			pending:  []pendingMessage{{name: ic.Channel, target: t, msg: model.RichMessage{Text: text}}},
+		}
+	}
+
+	if err := mc.notifier.Send(t, text); err != nil {
+		return "", err
+	}
+
+	return "I've posted the standup message to " + ic.Channel, nil
+}
+
+// listAbsentAction returns which of the intent's members aren't
+// active according to the notifier and who couldn't be found
+func listAbsentAction(mc mqttClient, _ model.Payload, ic model.IntentConfig) (string, error) {
+	pc, ok := mc.notifier.(presenceChecker)
+	if !ok {
+		return "", errPresenceUnsupported
+	}
+
+	var absent, missing []string
+	for _, m := range ic.Members {
+		t, err := mc.notifier.Resolve(m, TargetUser)
+		if err != nil {
+			log.Printf("failed to resolve member %q: %s\n", m, err)
+			missing = append(missing, m)
+			continue
+		}
+
+		present, err := pc.Present(t)
+		if err != nil {
+			return "", err
+		}
+
+		if !present {
+			absent = append(absent, m)
+		}
+	}
+
+	var reply string
+	switch {
+	case len(absent) == 1:
+		reply = absent[0] + " is absent"
+	case len(absent) > 1:
+		reply = fmt.Sprintf("%s are absent", joinNames(absent))
+	case len(missing) > 0:
+		reply = "Everyone I found is here"
+	default:
+		reply = "Everyone is here"
+	}
+
+	if len(missing) > 0 {
+		reply += ", couldn't find " + joinNames(missing)
+	}
+
+	return reply, nil
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func containsString(list []string, s string) bool {
+	return indexOf(list, s) >= 0
+}
+
+// indexOf returns the index of s in list or -1
+func indexOf(list []string, s string) int {
+	for i, l := range list {
+		if l == s {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// joinNames joins names into a spoken list,
+// i.e "Alice, Bob and Carol"
+func joinNames(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+type testPresenceNotifier struct {
+	testNotifier
+	present map[string]bool
+	missing map[string]bool
+	err     error
+}
+
+func (n testPresenceNotifier) Resolve(name string, kind TargetKind) (Target, error) {
+	if n.missing[name] {
+		return Target{}, notFoundError{name: name, kind: kind}
+	}
+
+	return n.testNotifier.Resolve(name, kind)
+}
+
+func (n testPresenceNotifier) Present(t Target) (bool, error) {
+	return n.present[t.ID], n.err
+}
+
+func TestPingAction(t *testing.T) {
+	payload := model.Payload{Slots: []model.Slot{{Name: "slack_names", Value: model.ValueType{Value: "devops"}}}}
+
+	t.Run("resolves with kind", func(t *testing.T) {
+		var gotKind TargetKind
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.notifier = kindRecorder{kind: &gotKind}
+
+		got, err := pingAction(TargetChannel)(mc, payload, model.IntentConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != "I've messaged devops" {
+			t.Errorf("expected messaged reply but got %q", got)
+		}
+
+		if gotKind != TargetChannel {
+			t.Errorf("expected kind %d but got %d", TargetChannel, gotKind)
+		}
+	})
+
+	t.Run("channel slot resolves channels", func(t *testing.T) {
+		var gotKind TargetKind
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.config.SnipsConfig.ChannelSlotName = "slack_channels"
+		mc.notifier = kindRecorder{kind: &gotKind}
+
+		p := model.Payload{Slots: []model.Slot{{Name: "slack_channels", Value: model.ValueType{Value: "devops"}}}}
+
+		if _, err := pingAction(TargetAny)(mc, p, model.IntentConfig{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if gotKind != TargetChannel {
+			t.Errorf("expected kind %d but got %d", TargetChannel, gotKind)
+		}
+	})
+
+	t.Run("renders messages for the target", func(t *testing.T) {
+		defer func(fn func() time.Time) { messageTime = fn }(messageTime)
+		messageTime = func() time.Time { return time.Date(2019, time.January, 11, 9, 30, 0, 0, time.Local) }
+
+		var texts []string
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.config.SlackConfig.Messages = []string{"{{.Mention}} {{.FirstName}}, standup in the {{.Site}} this {{.Weekday}}"}
+		mc.notifier = textRecorder{texts: &texts}
+
+		p := model.Payload{
+			SiteID: "kitchen",
+			Slots:  []model.Slot{{Name: "slack_names", Value: model.ValueType{Value: "Jodie Foster"}}},
+		}
+
+		if _, err := pingAction(TargetAny)(mc, p, model.IntentConfig{}); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{"<@Jodie Foster> Jodie, standup in the kitchen this Friday"}
+		if !cmp.Equal(want, texts) {
+			t.Error(cmp.Diff(want, texts))
+		}
+	})
+
+	t.Run("sends rich messages", func(t *testing.T) {
+		var got []model.RichMessage
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.config.SlackConfig.Messages = nil
+		mc.config.SlackConfig.RichMessages = []model.RichMessage{
+			{Text: "standup {{.Name}}!", Blocks: json.RawMessage(`[{"type": "section", "text": {"type": "mrkdwn", "text": "{{.Mention}}"}}]`)},
+		}
+		mc.notifier = richRecorder{msgs: &got}
+
+		p := model.Payload{Slots: []model.Slot{{Name: "slack_names", Value: model.ValueType{Value: "Jodie"}}}}
+		if _, err := pingAction(TargetAny)(mc, p, model.IntentConfig{}); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []model.RichMessage{
+			{Text: "standup Jodie!", Blocks: json.RawMessage(`[{"text":{"text":"Jodie","type":"mrkdwn"},"type":"section"}]`)},
+		}
+
+		if !cmp.Equal(want, got) {
+			t.Error(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("missing slots", func(t *testing.T) {
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+
+		_, err := pingAction(TargetAny)(mc, model.Payload{}, model.IntentConfig{})
+		if err != errMissingSlots {
+			t.Fatalf("expected error %q but got %q", errMissingSlots, err)
+		}
+	})
+
+	t.Run("multiple slots with failures", func(t *testing.T) {
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.notifier = failingNotifier{
+			resolveErrs: map[string]error{"Carol": notFoundError{name: "Carol"}},
+			sendErrs:    map[string]error{"Dave": errors.New("channel_not_found")},
+		}
+
+		p := model.Payload{Slots: []model.Slot{
+			{Name: "slack_names", Value: model.ValueType{Value: "Alice"}},
+			{Name: "slack_names", Value: model.ValueType{Value: "Bob"}},
+			{Name: "slack_names", Value: model.ValueType{Value: "Carol"}},
+			{Name: "slack_names", Value: model.ValueType{Value: "Dave"}},
+			{Name: "slack_names", Value: model.ValueType{Value: "Alice"}},
+		}}
+
+		got, err := pingAction(TargetAny)(mc, p, model.IntentConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := "I've messaged Alice and Bob, couldn't find Carol, couldn't message Dave"
+		if got != want {
+			t.Errorf("expected %q but got %q", want, got)
+		}
+	})
+}
+
+func TestPingActionConfidence(t *testing.T) {
+	slot := func(name string, confidence float64) model.Slot {
+		return model.Slot{Name: "slack_names", Confidence: confidence, Value: model.ValueType{Value: name}}
+	}
+
+	t.Run("low slot confidence asks to confirm", func(t *testing.T) {
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.config.SnipsConfig.MinSlotConfidence = 0.6
+		mc.notifier = failingNotifier{sendErrs: map[string]error{"Bob": errors.New("should not be sent")}}
+
+		p := model.Payload{
+			Intent: model.Intent{Probability: 1},
+			Slots:  []model.Slot{slot("Alice", 0.9), slot("Bob", 0.4)},
+		}
+
+		_, err := pingAction(TargetAny)(mc, p, model.IntentConfig{})
+
+		cr, ok := err.(confirmationRequired)
+		if !ok {
+			t.Fatalf("expected confirmation required but got %v", err)
+		}
+
+		want := "I've messaged Alice. Did you mean Bob?"
+		if cr.Error() != want {
+			t.Errorf("expected %q but got %q", want, cr.Error())
+		}
+
+		if len(cr.pending) != 1 || cr.pending[0].target.ID != "Bob" {
+			t.Errorf("expected Bob to be pending but got %v", cr.pending)
+		}
+	})
+
+	t.Run("low intent probability asks to confirm all", func(t *testing.T) {
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.config.SnipsConfig.MinIntentProbability = 0.7
+
+		p := model.Payload{
+			Intent: model.Intent{Probability: 0.5},
+			Slots:  []model.Slot{slot("Alice", 1), slot("Bob", 1)},
+		}
+
+		_, err := pingAction(TargetAny)(mc, p, model.IntentConfig{})
+
+		want := "Did you mean Alice and Bob?"
+		if err == nil || err.Error() != want {
+			t.Fatalf("expected %q but got %v", want, err)
+		}
+	})
+
+	t.Run("unresolved uncertain name", func(t *testing.T) {
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.config.SnipsConfig.MinSlotConfidence = 0.6
+		mc.notifier = failingNotifier{resolveErrs: map[string]error{"Bob": notFoundError{name: "Bob"}}}
+
+		p := model.Payload{Slots: []model.Slot{slot("Bob", 0.4)}}
+
+		_, err := pingAction(TargetAny)(mc, p, model.IntentConfig{})
+		if _, ok := err.(notFoundError); !ok {
+			t.Fatalf("expected not found error but got %v", err)
+		}
+	})
+}
+
+func TestPingSummary(t *testing.T) {
+	notFound := notFoundError{name: "x"}
+
+	specs := []struct {
+		in   []pingResult
+		want string
+	}{
+		{[]pingResult{{name: "Alice"}}, "I've messaged Alice"},
+		{[]pingResult{{name: "Alice"}, {name: "Carol", err: notFound}}, "I've messaged Alice, couldn't find Carol"},
+		{[]pingResult{{name: "Carol", err: notFound}, {name: "Dave", err: notFound}}, "I couldn't find Carol and Dave"},
+		{[]pingResult{{name: "Carol", err: notFound}, {name: "Dave", err: errors.New("x")}}, "I couldn't find Carol, couldn't message Dave"},
+	}
+
+	for _, s := range specs {
+		if got := pingSummary(s.in); got != s.want {
+			t.Errorf("expected %q but got %q", s.want, got)
+		}
+	}
+}
+
+func TestStandupMessageAction(t *testing.T) {
+	ic := model.IntentConfig{Channel: "general", Message: "Standup time"}
+
+	t.Run("posts message", func(t *testing.T) {
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+
+		got, err := standupMessageAction(mc, model.Payload{}, ic)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := "I've posted the standup message to general"
+		if got != want {
+			t.Errorf("expected %q but got %q", want, got)
+		}
+	})
+
+	t.Run("low intent probability asks to confirm", func(t *testing.T) {
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.config.SnipsConfig.MinIntentProbability = 0.7
+
+		_, err := standupMessageAction(mc, model.Payload{Intent: model.Intent{Probability: 0.2}}, ic)
+
+		want := "Did you want me to post the standup message to general?"
+		if err == nil || err.Error() != want {
+			t.Fatalf("expected %q but got %v", want, err)
+		}
+	})
+
+	t.Run("send error", func(t *testing.T) {
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.notifier = testNotifier{sendErr: errors.New("not_in_channel")}
+
+		_, err := standupMessageAction(mc, model.Payload{}, ic)
+		if err == nil || err.Error() != "not_in_channel" {
+			t.Fatalf("expected send error but got %v", err)
+		}
+	})
+}
+
+func TestListAbsentAction(t *testing.T) {
+	ic := model.IntentConfig{Members: []string{"Alice", "Bob", "Carol"}}
+
+	specs := []struct {
+		present map[string]bool
+		missing map[string]bool
+		want    string
+	}{
+		{map[string]bool{"Alice": true, "Bob": true, "Carol": true}, nil, "Everyone is here"},
+		{map[string]bool{"Alice": true, "Bob": true}, nil, "Carol is absent"},
+		{map[string]bool{}, nil, "Alice, Bob and Carol are absent"},
+		{map[string]bool{"Alice": true}, map[string]bool{"Bob": true}, "Carol is absent, couldn't find Bob"},
+		{map[string]bool{"Alice": true, "Carol": true}, map[string]bool{"Bob": true}, "Everyone I found is here, couldn't find Bob"},
+	}
+
+	for _, s := range specs {
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.notifier = testPresenceNotifier{present: s.present, missing: s.missing}
+
+		got, err := listAbsentAction(mc, model.Payload{}, ic)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != s.want {
+			t.Errorf("expected %q but got %q", s.want, got)
+		}
+	}
+
+	t.Run("presence unsupported", func(t *testing.T) {
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+
+		_, err := listAbsentAction(mc, model.Payload{}, ic)
+		if err != errPresenceUnsupported {
+			t.Fatalf("expected error %q but got %q", errPresenceUnsupported, err)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.notifier = dryRunNotifier{testPresenceNotifier{present: map[string]bool{"Alice": true, "Bob": true}}}
+
+		got, err := listAbsentAction(mc, model.Payload{}, ic)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != "Carol is absent" {
+			t.Errorf("expected Carol is absent but got %q", got)
+		}
+	})
+}
+
+func TestJoinNames(t *testing.T) {
+	specs := []struct {
+		in   []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"Alice"}, "Alice"},
+		{[]string{"Alice", "Bob"}, "Alice and Bob"},
+		{[]string{"Alice", "Bob", "Carol"}, "Alice, Bob and Carol"},
+	}
+
+	for _, s := range specs {
+		if got := joinNames(s.in); got != s.want {
+			t.Errorf("expected %q but got %q", s.want, got)
+		}
+	}
+}
+
+type failingNotifier struct {
+	resolveErrs map[string]error
+	sendErrs    map[string]error
+	sent        *[]Target
+}
+
+func (f failingNotifier) Resolve(name string, kind TargetKind) (Target, error) {
+	return Target{ID: name, Name: name, Kind: kind}, f.resolveErrs[name]
+}
+
+func (f failingNotifier) Send(t Target, _ string) error {
+	if f.sent != nil {
+		*f.sent = append(*f.sent, t)
+	}
+
+	return f.sendErrs[t.ID]
+}
+
+type textRecorder struct {
+	testNotifier
+	texts *[]string
+}
+
+func (r textRecorder) Resolve(name string, kind TargetKind) (Target, error) {
+	return Target{ID: name, Name: name, Kind: kind, Mention: "<@" + name + ">"}, nil
+}
+
+func (r textRecorder) Send(_ Target, text string) error {
+	*r.texts = append(*r.texts, text)
+	return nil
+}
+
+type richRecorder struct {
+	testNotifier
+	msgs *[]model.RichMessage
+}
+
+func (r richRecorder) SendRich(_ Target, m model.RichMessage) error {
+	*r.msgs = append(*r.msgs, m)
+	return nil
+}
+
+type kindRecorder struct {
+	testNotifier
+	kind *TargetKind
+}
+
+func (k kindRecorder) Resolve(name string, kind TargetKind) (Target, error) {
+	*k.kind = kind
+	return k.testNotifier.Resolve(name, kind)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// backoff returns exponentially growing delays capped at max,
+// each randomised between half and the whole delay so clients
+// retrying together don't all retry at the same time
+type backoff struct {
+	base    time.Duration
+	max     time.Duration
+	attempt int
+
+	// jitter returns a random duration in [0, n)
+	jitter func(n int64) int64
+}
+
+func newBackoff(base, max time.Duration) *backoff {
+	if max < base {
+		max = base
+	}
+
+	return &backoff{base: base, max: max, jitter: rand.Int63n}
+}
+
+// next returns the delay before the next attempt
+func (b *backoff) next() time.Duration {
+	d := b.max
+	if b.attempt < 32 {
+		if e := b.base << uint(b.attempt); e > 0 && e < b.max {
+			d = e
+		}
+	}
+
+	b.attempt++
+
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+
+	return half + time.Duration(b.jitter(int64(half)+1))
+}
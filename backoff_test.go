@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	t.Run("doubles up to max", func(t *testing.T) {
+		b := newBackoff(time.Second, 10*time.Second)
+		b.jitter = func(n int64) int64 { return n - 1 }
+
+		want := []time.Duration{
+			time.Second,
+			2 * time.Second,
+			4 * time.Second,
+			8 * time.Second,
+			10 * time.Second,
+			10 * time.Second,
+		}
+
+		for i, w := range want {
+			if got := b.next(); got != w {
+				t.Errorf("attempt %d expected %s but got %s", i+1, w, got)
+			}
+		}
+	})
+
+	t.Run("jitters between half and the whole delay", func(t *testing.T) {
+		b := newBackoff(4*time.Second, time.Minute)
+		b.jitter = func(n int64) int64 { return 0 }
+
+		if got := b.next(); got != 2*time.Second {
+			t.Errorf("expected %s but got %s", 2*time.Second, got)
+		}
+	})
+
+	t.Run("never overflows", func(t *testing.T) {
+		b := newBackoff(time.Second, time.Hour)
+		b.attempt = 100
+
+		if got := b.next(); got < 30*time.Minute || got > time.Hour {
+			t.Errorf("expected delay within max but got %s", got)
+		}
+	})
+
+	t.Run("max below base", func(t *testing.T) {
+		b := newBackoff(time.Second, 0)
+		b.jitter = func(n int64) int64 { return n - 1 }
+
+		if got := b.next(); got != time.Second {
+			t.Errorf("expected %s but got %s", time.Second, got)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bluele/slack"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+// slackCache is the on disk copy of the slack users, channels
+// and user groups so names resolve after a restart without slack
+type slackCache struct {
+	UpdatedAt  time.Time        `json:"updated_at"`
+	Users      []*slack.User    `json:"users"`
+	Profiles   model.Profiles   `json:"profiles"`
+	Channels   []*slack.Channel `json:"channels"`
+	Usergroups []slackUsergroup `json:"usergroups"`
+}
+
+// staleCacheError is returned when the cache
+// on disk is older than the configured max age
+type staleCacheError struct {
+	age    time.Duration
+	maxAge time.Duration
+}
+
+func (e staleCacheError) Error() string {
+	return fmt.Sprintf("cache is stale, updated %s ago exceeding max age %s", e.age, e.maxAge)
+}
+
+// cacheStore reads and writes the slack cache file
+type cacheStore struct {
+	path   string
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+func newCacheStore(path string, maxAge time.Duration) cacheStore {
+	return cacheStore{path: path, maxAge: maxAge, now: time.Now}
+}
+
+// enabled reports whether a cache path is configured
+func (cs cacheStore) enabled() bool {
+	return cs.path != ""
+}
+
+// load reads the cache from disk returning staleCacheError
+// when it's older than max age, a zero max age never expires
+func (cs cacheStore) load() (slackCache, error) {
+	var c slackCache
+
+	b, err := ioutil.ReadFile(cs.path)
+	if err != nil {
+		return c, err
+	}
+
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, err
+	}
+
+	if age := cs.age(c); cs.maxAge > 0 && age > cs.maxAge {
+		return c, staleCacheError{age: age, maxAge: cs.maxAge}
+	}
+
+	return c, nil
+}
+
+// save writes the cache to disk
+func (cs cacheStore) save(c slackCache) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(cs.path, b)
+}
+
+// writeFileAtomic writes b to a temporary file and renames it
+// over the file at path so a crash never leaves it half written
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// age returns how long ago the cache was updated
+func (cs cacheStore) age(c slackCache) time.Duration {
+	return cs.now().Sub(c.UpdatedAt).Truncate(time.Second)
+}
+
+// loadSlackCache fills the directory from the cache
+// file unless it's missing, unreadable or stale
+func loadSlackCache(cs cacheStore, dir *directory) {
+	c, err := cs.load()
+	if err != nil {
+		log.Println("failed to load slack cache:", err)
+		return
+	}
+
+	dir.SetUsers(c.Users)
+	dir.SetProfiles(c.Profiles)
+	dir.SetChannels(c.Channels)
+	dir.SetUsergroups(c.Usergroups)
+	dir.SetUpdatedAt(c.UpdatedAt)
+	log.Printf("loaded %d users, %d channels and %d user groups from cache updated %s ago\n",
+		len(c.Users), len(c.Channels), len(c.Usergroups), cs.age(c))
+}
+
+// refreshSlackCache updates the users and channels from slack keeping
+// the previous ones when either fails and saves them to the cache file.
+// User groups need an extra scope and paid plan so failing to load
+// them is only logged.
+func refreshSlackCache(l slackLoader, cs cacheStore, dir *directory) {
+	users, profiles, uerr := l.Users()
+	if uerr != nil {
+		log.Println("get slack users failed", uerr)
+	} else {
+		log.Printf("stored %d users in cache\n", len(users))
+		dir.SetUsers(users)
+		dir.SetProfiles(profiles)
+	}
+
+	chls, cerr := l.Conversations(dir)
+	if cerr != nil {
+		log.Println("get slack channels failed", cerr)
+	} else {
+		log.Printf("stored %d channels in cache\n", len(chls))
+		dir.SetChannels(chls)
+	}
+
+	if groups, err := listUsergroups(l.token); err != nil {
+		log.Println("get slack user groups failed", err)
+	} else {
+		log.Printf("stored %d user groups in cache\n", len(groups))
+		dir.SetUsergroups(groups)
+	}
+
+	if uerr != nil || cerr != nil {
+		if updated := dir.UpdatedAt(); !updated.IsZero() {
+			log.Printf("using slack cache updated %s ago\n", cs.age(slackCache{UpdatedAt: updated}))
+		}
+		return
+	}
+
+	dir.SetUpdatedAt(cs.now())
+
+	if !cs.enabled() {
+		return
+	}
+
+	c := slackCache{
+		UpdatedAt:  dir.UpdatedAt(),
+		Users:      dir.Users(),
+		Profiles:   dir.Profiles(),
+		Channels:   dir.Channels(),
+		Usergroups: dir.Usergroups(),
+	}
+	if err := cs.save(c); err != nil {
+		log.Println("failed to save slack cache:", err)
+	}
+}
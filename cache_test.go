@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluele/slack"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+func TestCacheStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssp-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2019, 1, 7, 9, 30, 0, 0, time.UTC)
+	path := filepath.Join(dir, "cache.json")
+
+	cs := newCacheStore(path, time.Hour)
+	cs.now = func() time.Time { return now }
+
+	cache := slackCache{
+		UpdatedAt: now.Add(-30 * time.Minute),
+		Users: []*slack.User{
+			{Id: "U1", Name: "jfoster", Profile: &slack.ProfileInfo{RealName: "Jodie Foster"}},
+		},
+		Profiles: model.Profiles{"U1": {"display_name": "Jo"}},
+		Channels: []*slack.Channel{
+			{Id: "C1", Name: "devops", RawTopic: json.RawMessage(`{"value":"ops"}`), RawPurpose: json.RawMessage(`null`)},
+		},
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := cs.load()
+		if !os.IsNotExist(err) {
+			t.Fatalf("expected not exist error but got %v", err)
+		}
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		if err := cs.save(cache); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := cs.load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !cmp.Equal(cache, got) {
+			t.Error(cmp.Diff(cache, got))
+		}
+
+		if got := cs.age(got); got != 30*time.Minute {
+			t.Errorf("expected age %s but got %s", 30*time.Minute, got)
+		}
+	})
+
+	t.Run("stale cache", func(t *testing.T) {
+		cs := cs
+		cs.now = func() time.Time { return now.Add(time.Hour) }
+
+		_, err := cs.load()
+
+		want := "cache is stale, updated 1h30m0s ago exceeding max age 1h0m0s"
+		if err == nil || err.Error() != want {
+			t.Fatalf("expected error %q but got %v", want, err)
+		}
+	})
+
+	t.Run("no max age", func(t *testing.T) {
+		cs := newCacheStore(path, 0)
+		cs.now = func() time.Time { return now.Add(24 * 365 * time.Hour) }
+
+		if _, err := cs.load(); err != nil {
+			t.Fatal("expected no error but got", err)
+		}
+	})
+
+	t.Run("save leaves no temporary files", func(t *testing.T) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(files) != 1 {
+			t.Errorf("expected only the cache file but got %d files", len(files))
+		}
+	})
+}
+
+func TestLoadSlackCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssp-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2019, 1, 7, 9, 30, 0, 0, time.UTC)
+	cs := newCacheStore(filepath.Join(dir, "cache.json"), time.Hour)
+	cs.now = func() time.Time { return now }
+
+	cache := slackCache{
+		UpdatedAt:  now.Add(-30 * time.Minute),
+		Users:      []*slack.User{{Id: "U1", Name: "jfoster"}},
+		Channels:   []*slack.Channel{{Id: "C1", Name: "devops"}},
+		Usergroups: []slackUsergroup{testUsergroup("S1", "backend", "Backend Team")},
+	}
+
+	if err := cs.save(cache); err != nil {
+		t.Fatal(err)
+	}
+
+	d := newDirectory()
+	loadSlackCache(cs, d)
+
+	if len(d.Users()) != 1 || len(d.Channels()) != 1 || len(d.Usergroups()) != 1 || !d.UpdatedAt().Equal(cache.UpdatedAt) {
+		t.Errorf("expected the cache to be loaded but got %d users, %d channels and %d user groups updated at %s",
+			len(d.Users()), len(d.Channels()), len(d.Usergroups()), d.UpdatedAt())
+	}
+
+	t.Run("stale cache", func(t *testing.T) {
+		cs := cs
+		cs.now = func() time.Time { return now.Add(time.Hour) }
+
+		d := newDirectory()
+		loadSlackCache(cs, d)
+
+		if len(d.Users()) != 0 || !d.UpdatedAt().IsZero() {
+			t.Errorf("expected a stale cache to be refused but got %d users", len(d.Users()))
+		}
+	})
+}
+
+func TestRefreshSlackCache(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ssp-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	f, done := newFakeSlack(map[string][]string{
+		"users.list": {`"members": [{"id": "U1", "name": "jfoster"}]`},
+		"conversations.list?types=public_channel": {`"channels": [{"id": "C1", "name": "devops"}]`},
+		"usergroups.list":                         {`"usergroups": [{"id": "S1", "handle": "backend", "name": "Backend Team"}]`},
+	})
+	defer done()
+
+	now := time.Date(2019, 1, 7, 9, 30, 0, 0, time.UTC)
+	cs := newCacheStore(filepath.Join(tmp, "cache.json"), 0)
+	cs.now = func() time.Time { return now }
+
+	l := slackLoader{token: "xoxb-1234", limit: 1, types: []string{"public_channel"}}
+	dir := newDirectory()
+
+	t.Run("refreshes and saves", func(t *testing.T) {
+		refreshSlackCache(l, cs, dir)
+
+		if len(dir.Users()) != 1 || len(dir.Channels()) != 1 || len(dir.Usergroups()) != 1 {
+			t.Fatalf("expected the directory to be loaded but got %d users, %d channels and %d user groups",
+				len(dir.Users()), len(dir.Channels()), len(dir.Usergroups()))
+		}
+
+		if !dir.UpdatedAt().Equal(now) {
+			t.Errorf("expected updated at %s but got %s", now, dir.UpdatedAt())
+		}
+
+		c, err := cs.load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !c.UpdatedAt.Equal(now) || len(c.Users) != 1 || len(c.Channels) != 1 || len(c.Usergroups) != 1 {
+			t.Errorf("expected the refresh to be saved but got %+v", c)
+		}
+	})
+
+	t.Run("user groups failing is only logged", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		f.errors["usergroups.list"] = "missing_scope"
+		defer delete(f.errors, "usergroups.list")
+
+		refreshSlackCache(l, cs, dir)
+
+		if !dir.UpdatedAt().Equal(now) || len(dir.Usergroups()) != 1 {
+			t.Errorf("expected a refresh keeping user groups but got %s with %d user groups",
+				dir.UpdatedAt(), len(dir.Usergroups()))
+		}
+	})
+
+	t.Run("keeps the previous on failure", func(t *testing.T) {
+		updated := now
+		now = now.Add(time.Hour)
+		f.errors["users.list"] = "fatal_error"
+		f.pages["conversations.list?types=public_channel"] = []string{`"channels": [{"id": "C1", "name": "devops"}, {"id": "C2", "name": "general"}]`}
+
+		refreshSlackCache(l, cs, dir)
+
+		if len(dir.Users()) != 1 || len(dir.Channels()) != 2 {
+			t.Errorf("expected the previous users and new channels but got %d users and %d channels",
+				len(dir.Users()), len(dir.Channels()))
+		}
+
+		if !dir.UpdatedAt().Equal(updated) {
+			t.Errorf("expected updated at to stay %s but got %s", updated, dir.UpdatedAt())
+		}
+
+		c, err := cs.load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !c.UpdatedAt.Equal(updated) || len(c.Channels) != 1 {
+			t.Errorf("expected the cache not to be saved but got %+v", c)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ConnState is the state of the connection to the mqtt broker
+type ConnState int
+
+const (
+	StateDisconnected ConnState = iota
+	StateConnecting
+	StateConnected
+	// StateReconnecting is entered when an established
+	// connection is lost and paho is reconnecting
+	StateReconnecting
+	// StateFailed is entered when the configured
+	// connect attempts have been exhausted
+	StateFailed
+)
+
+var connStateNames = map[ConnState]string{
+	StateDisconnected: "disconnected",
+	StateConnecting:   "connecting",
+	StateConnected:    "connected",
+	StateReconnecting: "reconnecting",
+	StateFailed:       "failed",
+}
+
+func (s ConnState) String() string {
+	if n, ok := connStateNames[s]; ok {
+		return n
+	}
+
+	return fmt.Sprintf("ConnState(%d)", int(s))
+}
+
+// connTransitions lists the states each state may move to
+var connTransitions = map[ConnState][]ConnState{
+	StateDisconnected: {StateConnecting},
+	StateConnecting:   {StateConnected, StateFailed, StateDisconnected},
+	StateConnected:    {StateReconnecting, StateDisconnected},
+	StateReconnecting: {StateConnected, StateDisconnected},
+	StateFailed:       {StateConnecting, StateDisconnected},
+}
+
+// invalidTransitionError is returned when moving
+// between states which aren't connected
+type invalidTransitionError struct {
+	from, to ConnState
+}
+
+func (e invalidTransitionError) Error() string {
+	return fmt.Sprintf("invalid connection state transition from %s to %s", e.from, e.to)
+}
+
+// connStateFn is called after every state transition
+type connStateFn func(from, to ConnState)
+
+// connStateMachine tracks the connection state notifying
+// observers of each transition, safe for concurrent use
+type connStateMachine struct {
+	mu        sync.Mutex
+	state     ConnState
+	observers []connStateFn
+}
+
+func newConnStateMachine() *connStateMachine {
+	return &connStateMachine{state: StateDisconnected}
+}
+
+// State returns the current connection state
+func (m *connStateMachine) State() ConnState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.state
+}
+
+// Observe registers fn to be called after each transition
+func (m *connStateMachine) Observe(fn connStateFn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.observers = append(m.observers, fn)
+}
+
+// transition moves to the state notifying the observers, moving
+// to the current state does nothing and invalid moves are errors
+func (m *connStateMachine) transition(to ConnState) error {
+	m.mu.Lock()
+
+	from := m.state
+	if from == to {
+		m.mu.Unlock()
+		return nil
+	}
+
+	if !canTransition(from, to) {
+		m.mu.Unlock()
+		return invalidTransitionError{from: from, to: to}
+	}
+
+	m.state = to
+	observers := append([]connStateFn(nil), m.observers...)
+	m.mu.Unlock()
+
+	// Called without the lock so observers may read the state
+	for _, fn := range observers {
+		fn(from, to)
+	}
+
+	return nil
+}
+
+func canTransition(from, to ConnState) bool {
+	for _, s := range connTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConnStateMachine(t *testing.T) {
+	type change struct {
+		From, To ConnState
+	}
+
+	t.Run("observes transitions", func(t *testing.T) {
+		m := newConnStateMachine()
+
+		var got []change
+		m.Observe(func(from, to ConnState) {
+			got = append(got, change{from, to})
+		})
+
+		for _, s := range []ConnState{
+			StateConnecting,
+			StateConnected,
+			StateConnected,
+			StateReconnecting,
+			StateConnected,
+			StateDisconnected,
+		} {
+			if err := m.transition(s); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		want := []change{
+			{StateDisconnected, StateConnecting},
+			{StateConnecting, StateConnected},
+			{StateConnected, StateReconnecting},
+			{StateReconnecting, StateConnected},
+			{StateConnected, StateDisconnected},
+		}
+
+		if !cmp.Equal(want, got) {
+			t.Error(cmp.Diff(want, got))
+		}
+
+		if m.State() != StateDisconnected {
+			t.Errorf("expected state %s but got %s", StateDisconnected, m.State())
+		}
+	})
+
+	t.Run("invalid transitions", func(t *testing.T) {
+		specs := []struct {
+			path []ConnState
+			to   ConnState
+		}{
+			{nil, StateConnected},
+			{nil, StateReconnecting},
+			{[]ConnState{StateConnecting}, StateReconnecting},
+			{[]ConnState{StateConnecting, StateConnected}, StateFailed},
+			{[]ConnState{StateConnecting, StateFailed}, StateConnected},
+		}
+
+		for _, s := range specs {
+			m := newConnStateMachine()
+			for _, p := range s.path {
+				if err := m.transition(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			from := m.State()
+			m.Observe(func(ConnState, ConnState) {
+				t.Error("expected no observer to be called")
+			})
+
+			err := m.transition(s.to)
+			want := invalidTransitionError{from: from, to: s.to}
+			if err != want {
+				t.Errorf("expected error %q but got %v", want, err)
+			}
+
+			if m.State() != from {
+				t.Errorf("expected state to remain %s but got %s", from, m.State())
+			}
+		}
+	})
+
+	t.Run("state names", func(t *testing.T) {
+		if got := StateReconnecting.String(); got != "reconnecting" {
+			t.Errorf("expected reconnecting but got %q", got)
+		}
+
+		if got := ConnState(42).String(); got != "ConnState(42)" {
+			t.Errorf("expected ConnState(42) but got %q", got)
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluele/slack"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+// directory holds the slack users, channels and user groups indexed
+// by ID, normalized name and handle. It's safe for concurrent use
+// as the cache refresh and message handler run separately.
+type directory struct {
+	mu sync.RWMutex
+
+	users         []*slack.User
+	usersByID     map[string]*slack.User
+	usersByName   map[string][]*slack.User
+	usersByHandle map[string]*slack.User
+	profiles      model.Profiles
+
+	channels       []*slack.Channel
+	channelsByID   map[string]*slack.Channel
+	channelsByName map[string]*slack.Channel
+
+	usergroups       []slackUsergroup
+	usergroupsByName map[string]slackUsergroup
+
+	updatedAt time.Time
+}
+
+func newDirectory() *directory {
+	d := &directory{}
+	d.SetUsers(nil)
+	d.SetChannels(nil)
+	d.SetUsergroups(nil)
+	return d
+}
+
+// SetUsers replaces the users and their indexes,
+// nil and deleted users aren't indexed
+func (d *directory) SetUsers(users []*slack.User) {
+	byID := map[string]*slack.User{}
+	byName := map[string][]*slack.User{}
+	byHandle := map[string]*slack.User{}
+
+	var kept []*slack.User
+	for _, u := range users {
+		if u == nil || u.Deleted {
+			continue
+		}
+
+		kept = append(kept, u)
+		byID[u.Id] = u
+		byHandle[strings.ToLower(u.Name)] = u
+
+		if u.Profile != nil {
+			n := normalizeName(u.Profile.RealName)
+			byName[n] = append(byName[n], u)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.users, d.usersByID, d.usersByName, d.usersByHandle = kept, byID, byName, byHandle
+}
+
+// SetProfiles replaces the profile fields of the users
+func (d *directory) SetProfiles(p model.Profiles) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.profiles = p
+}
+
+// SetChannels replaces the channels and their indexes
+func (d *directory) SetChannels(channels []*slack.Channel) {
+	byID := map[string]*slack.Channel{}
+	byName := map[string]*slack.Channel{}
+
+	var kept []*slack.Channel
+	for _, c := range channels {
+		if c == nil {
+			continue
+		}
+
+		kept = append(kept, c)
+		byID[c.Id] = c
+		byName[channelKey(c.Name)] = c
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.channels, d.channelsByID, d.channelsByName = kept, byID, byName
+}
+
+// SetUsergroups replaces the user groups indexing
+// them by both their name and handle
+func (d *directory) SetUsergroups(groups []slackUsergroup) {
+	byName := map[string]slackUsergroup{}
+	for _, g := range groups {
+		byName[channelKey(g.Handle)] = g
+		byName[channelKey(g.Name)] = g
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.usergroups, d.usergroupsByName = groups, byName
+}
+
+// SetUpdatedAt records when the directory was last refreshed
+func (d *directory) SetUpdatedAt(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.updatedAt = t
+}
+
+// UpdatedAt returns when the directory was last refreshed
+func (d *directory) UpdatedAt() time.Time {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.updatedAt
+}
+
+// Users returns a snapshot of the users which isn't
+// affected by later calls to SetUsers
+func (d *directory) Users() []*slack.User {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return append([]*slack.User(nil), d.users...)
+}
+
+// Profiles returns the profile fields of the users, it's
+// replaced rather than changed by SetProfiles so it's shared
+func (d *directory) Profiles() model.Profiles {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.profiles
+}
+
+// Channels returns a snapshot of the channels which
+// isn't affected by later calls to SetChannels
+func (d *directory) Channels() []*slack.Channel {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return append([]*slack.Channel(nil), d.channels...)
+}
+
+// Usergroups returns a snapshot of the user groups
+func (d *directory) Usergroups() []slackUsergroup {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return append([]slackUsergroup(nil), d.usergroups...)
+}
+
+// User returns the user with the ID
+func (d *directory) User(id string) (*slack.User, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	u, ok := d.usersByID[id]
+	return u, ok
+}
+
+// UserByHandle returns the user with the slack handle
+func (d *directory) UserByHandle(handle string) (*slack.User, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	u, ok := d.usersByHandle[strings.ToLower(handle)]
+	return u, ok
+}
+
+// UsersByName returns the users whose normalized
+// real name is the same as the normalized name
+func (d *directory) UsersByName(name string) []*slack.User {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return append([]*slack.User(nil), d.usersByName[normalizeName(name)]...)
+}
+
+// Channel returns the channel with the ID
+func (d *directory) Channel(id string) (*slack.Channel, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	c, ok := d.channelsByID[id]
+	return c, ok
+}
+
+// ChannelByName returns the channel with the name ignoring case,
+// punctuation and spacing so "dev ops" finds #devops and
+// "team backend" finds #team-backend
+func (d *directory) ChannelByName(name string) (*slack.Channel, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	c, ok := d.channelsByName[channelKey(name)]
+	return c, ok
+}
+
+// UsergroupByName returns the user group with the name or
+// handle ignoring case, punctuation and spacing like channels
+func (d *directory) UsergroupByName(name string) (slackUsergroup, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	g, ok := d.usergroupsByName[channelKey(name)]
+	return g, ok
+}
+
+// channelKey is the normalized name with spaces removed
+func channelKey(name string) string {
+	return strings.Replace(normalizeName(name), " ", "", -1)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/bluele/slack"
+)
+
+func TestDirectory(t *testing.T) {
+	d := newDirectory()
+
+	d.SetUsers([]*slack.User{
+		{Id: "U1", Name: "JFoster", Profile: &slack.ProfileInfo{RealName: "Jodie Foster"}},
+		{Id: "U2", Name: "ahopkins", Profile: &slack.ProfileInfo{RealName: "Anthony Hopkins"}},
+		{Id: "U3", Name: "ahopkins2", Profile: &slack.ProfileInfo{RealName: "Anthony  Hopkins"}},
+		{Id: "U4", Name: "sglenn", Profile: &slack.ProfileInfo{RealName: "Scott Glenn"}, Deleted: true},
+		{Id: "U5", Name: "bot"},
+		nil,
+	})
+
+	d.SetChannels([]*slack.Channel{
+		{Id: "C1", Name: "DevOps"},
+		nil,
+	})
+
+	t.Run("users snapshot skips deleted and nil", func(t *testing.T) {
+		if got := len(d.Users()); got != 4 {
+			t.Errorf("expected 4 users but got %d", got)
+		}
+	})
+
+	t.Run("user by id", func(t *testing.T) {
+		if u, ok := d.User("U2"); !ok || u.Name != "ahopkins" {
+			t.Errorf("expected user U2 but got %v", u)
+		}
+
+		if _, ok := d.User("U4"); ok {
+			t.Error("expected deleted user not to be indexed")
+		}
+	})
+
+	t.Run("user by handle", func(t *testing.T) {
+		if u, ok := d.UserByHandle("jfoster"); !ok || u.Id != "U1" {
+			t.Errorf("expected user U1 but got %v", u)
+		}
+	})
+
+	t.Run("users by normalized name", func(t *testing.T) {
+		if got := d.UsersByName("anthony hopkins"); len(got) != 2 {
+			t.Errorf("expected 2 users but got %d", len(got))
+		}
+
+		if got := d.UsersByName("Scott Glenn"); len(got) != 0 {
+			t.Errorf("expected no users but got %d", len(got))
+		}
+	})
+
+	t.Run("channels", func(t *testing.T) {
+		if got := len(d.Channels()); got != 1 {
+			t.Errorf("expected 1 channel but got %d", got)
+		}
+
+		if c, ok := d.Channel("C1"); !ok || c.Name != "DevOps" {
+			t.Errorf("expected channel C1 but got %v", c)
+		}
+
+		for _, n := range []string{"#devops", "dev ops", "Dev-Ops"} {
+			if c, ok := d.ChannelByName(n); !ok || c.Id != "C1" {
+				t.Errorf("expected channel C1 for %q but got %v", n, c)
+			}
+		}
+	})
+
+	t.Run("snapshot unaffected by set", func(t *testing.T) {
+		users := d.Users()
+		d.SetUsers(nil)
+
+		if len(users) != 4 {
+			t.Errorf("expected snapshot to keep 4 users but got %d", len(users))
+		}
+
+		if len(d.Users()) != 0 {
+			t.Errorf("expected no users but got %d", len(d.Users()))
+		}
+	})
+}
+
+func TestDirectoryConcurrentAccess(t *testing.T) {
+	d := newDirectory()
+	users := []*slack.User{{Id: "U1", Name: "jfoster", Profile: &slack.ProfileInfo{RealName: "Jodie Foster"}}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			d.SetUsers(users)
+		}()
+
+		go func() {
+			defer wg.Done()
+			d.UsersByName("Jodie Foster")
+			d.Users()
+		}()
+	}
+
+	wg.Wait()
+}
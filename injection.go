@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+const defaultInjectionTimeout = 5 * time.Minute
+
+var (
+	errInjectionTimeout = errors.New("timed out waiting for injection to complete")
+
+	// Failed injections are retried doubling
+	// the backoff after each attempt
+	injectionAttempts = 3
+	injectionBackoff  = 5 * time.Second
+)
+
+// entityPublisher injects entities and
+// waits for the injection to complete
+type entityPublisher interface {
+	InjectEntity(e *model.Entity) error
+}
+
+// injectionTracker matches injection complete messages to
+// the requests waiting on them, safe for concurrent use
+type injectionTracker struct {
+	mu      sync.Mutex
+	pending map[string]chan struct{}
+}
+
+func newInjectionTracker() *injectionTracker {
+	return &injectionTracker{pending: map[string]chan struct{}{}}
+}
+
+// expect registers the request ID returning a
+// channel closed when the injection completes
+func (t *injectionTracker) expect(id string) <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	done := make(chan struct{})
+	t.pending[id] = done
+	return done
+}
+
+// complete marks the request ID as completed
+// reporting if any request was waiting on it
+func (t *injectionTracker) complete(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	done, ok := t.pending[id]
+	if ok {
+		close(done)
+		delete(t.pending, id)
+	}
+
+	return ok
+}
+
+// forget stops waiting on the request ID
+func (t *injectionTracker) forget(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.pending, id)
+}
+
+// InjectEntity publishes the entity and waits for snips to
+// report the injection complete, logging how long it took
+func (mc mqttClient) InjectEntity(e *model.Entity) error {
+	timeout := mc.config.SnipsConfig.InjectionTimeout.Duration
+	if timeout == 0 {
+		timeout = defaultInjectionTimeout
+	}
+
+	done := mc.injections.expect(e.ID)
+	defer mc.injections.forget(e.ID)
+
+	start := time.Now()
+	err := mc.PublishEntity(e)
+	if err == nil {
+		select {
+		case <-done:
+		case <-time.After(timeout):
+			err = errInjectionTimeout
+		}
+	}
+
+	took := time.Since(start)
+	if err != nil {
+		log.Printf("injection %s failed after %s: %s\n", e.ID, took, err)
+		return err
+	}
+
+	log.Printf("injection %s completed in %s\n", e.ID, took)
+	return nil
+}
+
+// InjectionCompleteHandler completes the request
+// waiting on the injection complete message
+func (mc mqttClient) InjectionCompleteHandler(c mqtt.Client, msg mqtt.Message) {
+	var ic model.InjectionComplete
+	if err := json.Unmarshal(msg.Payload(), &ic); err != nil {
+		log.Printf("unmarshal injection complete error %s\n", err)
+		return
+	}
+
+	if !mc.injections.complete(ic.RequestID) {
+		log.Printf("injection %s completed, not requested by us\n", ic.RequestID)
+	}
+}
+
+// InjectionStatusHandler logs when snips last injected entities
+func (mc mqttClient) InjectionStatusHandler(c mqtt.Client, msg mqtt.Message) {
+	var is model.InjectionStatus
+	if err := json.Unmarshal(msg.Payload(), &is); err != nil {
+		log.Printf("unmarshal injection status error %s\n", err)
+		return
+	}
+
+	log.Println("snips last injected entities at", is.LastInjectionDate)
+}
+
+// entityInjector tracks the entity values last injected so only the
+// changes are published, persisting them when a path is configured
+type entityInjector struct {
+	path     string
+	injected map[string][]string
+}
+
+// newEntityInjector loads the values last injected from path,
+// a missing file means nothing has been injected yet
+func newEntityInjector(path string) *entityInjector {
+	ei := &entityInjector{path: path}
+	if path == "" {
+		return ei
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println("failed to load injected entities:", err)
+		}
+		return ei
+	}
+
+	if err := json.Unmarshal(b, &ei.injected); err != nil {
+		log.Println("failed to load injected entities:", err)
+	}
+
+	return ei
+}
+
+// inject publishes the changes between the values last injected
+// and values retrying failures, nothing is published when unchanged
+func (ei *entityInjector) inject(p entityPublisher, values map[string][]string) error {
+	e := model.DiffEntity(ei.injected, values)
+	if e == nil {
+		log.Println("slot values unchanged, skipping injection")
+		return nil
+	}
+
+	log.Println("publishing new slot values")
+
+	var err error
+	backoff := injectionBackoff
+
+	for try := 1; try <= injectionAttempts; try++ {
+		e.ID = newRequestID()
+		if err = p.InjectEntity(e); err == nil {
+			break
+		}
+
+		if try < injectionAttempts {
+			log.Printf("retrying injection in %s, attempt %d of %d failed\n", backoff, try, injectionAttempts)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ei.injected = values
+	if ei.path == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(ei.path, b)
+}
+
+// newRequestID returns a random ID to match
+// injection requests with their completion
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+
+	return fmt.Sprintf("%x", b)
+}
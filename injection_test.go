@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+type testPublisher struct {
+	entities []*model.Entity
+	errs     []error
+}
+
+// InjectEntity records a copy of the entity without its
+// random ID and returns the next error in order
+func (p *testPublisher) InjectEntity(e *model.Entity) error {
+	if e.ID == "" {
+		return errors.New("expected request ID")
+	}
+
+	p.entities = append(p.entities, &model.Entity{Ops: e.Ops})
+
+	var err error
+	if len(p.errs) > 0 {
+		err, p.errs = p.errs[0], p.errs[1:]
+	}
+
+	return err
+}
+
+func TestEntityInjector(t *testing.T) {
+	defer func(a int, b time.Duration) {
+		injectionAttempts, injectionBackoff = a, b
+	}(injectionAttempts, injectionBackoff)
+
+	injectionAttempts, injectionBackoff = 2, time.Millisecond
+
+	dir, err := ioutil.TempDir("", "ssp-injected")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "injected.json")
+	values := map[string][]string{"slack_names": {"Jodie Foster"}}
+
+	t.Run("publish failure isn't recorded", func(t *testing.T) {
+		p := &testPublisher{errs: []error{errInjectionTimeout, errors.New("publish error")}}
+		ei := newEntityInjector(path)
+
+		if err := ei.inject(p, values); err == nil || err.Error() != "publish error" {
+			t.Fatalf("expected publish error but got %v", err)
+		}
+
+		if len(p.entities) != injectionAttempts {
+			t.Errorf("expected %d attempts but got %d", injectionAttempts, len(p.entities))
+		}
+
+		if ei.injected != nil {
+			t.Errorf("expected nothing injected but got %v", ei.injected)
+		}
+
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected no injected file but got %v", err)
+		}
+	})
+
+	t.Run("injects from vanilla then skips unchanged", func(t *testing.T) {
+		p := &testPublisher{errs: []error{errInjectionTimeout}}
+		ei := newEntityInjector(path)
+
+		for i := 0; i < 2; i++ {
+			if err := ei.inject(p, values); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		e := &model.Entity{Ops: [][]interface{}{{"addFromVanilla", values}}}
+		want := []*model.Entity{e, e}
+		if !cmp.Equal(want, p.entities) {
+			t.Error(cmp.Diff(want, p.entities))
+		}
+	})
+
+	t.Run("loads injected values after restart", func(t *testing.T) {
+		p := &testPublisher{}
+		ei := newEntityInjector(path)
+
+		next := map[string][]string{"slack_names": {"Jodie Foster", "Ted Levine"}}
+		if err := ei.inject(p, next); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []*model.Entity{{Ops: [][]interface{}{{"add", map[string][]string{"slack_names": {"Ted Levine"}}}}}}
+		if !cmp.Equal(want, p.entities) {
+			t.Error(cmp.Diff(want, p.entities))
+		}
+
+		if got := newEntityInjector(path).injected; !cmp.Equal(next, got) {
+			t.Error(cmp.Diff(next, got))
+		}
+	})
+
+	t.Run("without a path", func(t *testing.T) {
+		p := &testPublisher{}
+		ei := newEntityInjector("")
+
+		if err := ei.inject(p, values); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(p.entities) != 1 {
+			t.Errorf("expected one entity published but got %d", len(p.entities))
+		}
+	})
+}
+
+func TestInjectEntity(t *testing.T) {
+	entity := &model.Entity{
+		ID:  "abc123",
+		Ops: [][]interface{}{{"add", map[string][]string{"slack_names": {"Ted Levine"}}}},
+	}
+
+	t.Run("completes on matching request ID", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildTestClient(client)
+
+		go func() {
+			// Wait until the request is expected before completing
+			for !mc.injections.complete("abc123") {
+				time.Sleep(time.Millisecond)
+			}
+		}()
+
+		if err := mc.InjectEntity(entity); err != nil {
+			t.Fatal(err)
+		}
+
+		gotMsg := string(client.token.messages[0].([]byte))
+		wantMsg := `{"id":"abc123","operations":[["add",{"slack_names":["Ted Levine"]}]]}`
+		if gotMsg != wantMsg {
+			t.Error(cmp.Diff(wantMsg, gotMsg))
+		}
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.config.SnipsConfig.InjectionTimeout = model.Duration{Duration: time.Millisecond}
+
+		if err := mc.InjectEntity(entity); err != errInjectionTimeout {
+			t.Fatalf("expected error %q but got %v", errInjectionTimeout, err)
+		}
+	})
+
+	t.Run("publish error", func(t *testing.T) {
+		wantErr := errors.New("publish error")
+		mc := buildTestClient(testMQTTClient{token: &testToken{err: wantErr}})
+
+		if err := mc.InjectEntity(entity); err != wantErr {
+			t.Fatalf("expected error %q but got %v", wantErr, err)
+		}
+	})
+}
+
+func TestInjectionCompleteHandler(t *testing.T) {
+	mc := buildTestClient(testMQTTClient{token: &testToken{}})
+	done := mc.injections.expect("abc123")
+
+	mc.InjectionCompleteHandler(mc.client, testMessage{payload: []byte(`{"requestId": "other"}`)})
+	mc.InjectionCompleteHandler(mc.client, testMessage{payload: []byte(`{"requestId": blah}`)})
+
+	select {
+	case <-done:
+		t.Fatal("expected injection to still be pending")
+	default:
+	}
+
+	mc.InjectionCompleteHandler(mc.client, testMessage{payload: []byte(`{"requestId": "abc123"}`)})
+
+	select {
+	case <-done:
+	default:
+		t.Fatal("expected injection to be completed")
+	}
+}
@@ -6,30 +6,29 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
-	"github.com/bluele/slack"
 	"github.com/jnormington/snips-slack-pinger/model"
 )
 
+const defaultRefreshInterval = 7 * time.Hour
+
 var (
 	generateConfig = flag.Bool("generate-config", false, "Output config template")
+	format         = flag.String("format", model.FormatJSON, "Config template format, json, yaml or toml")
 	config         = flag.String("config", "", "Config file to load")
 	dryrun         = flag.Bool("dry-run", false, "Dry run who will be messaged")
-	slackUsers     []*slack.User
-	slackChannels  []*slack.Channel
+	validateOnly   = flag.Bool("validate-config", false, "Validate the config outputting any errors as json")
 )
 
 func main() {
 	flag.Parse()
 
 	if *generateConfig {
-		s, err := model.GenerateConfig()
+		s, err := model.GenerateConfig(*format)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -42,25 +41,48 @@ func main() {
 		log.Fatal("missing configuration")
 	}
 
+	if *validateOnly {
+		os.Exit(validateConfig(*config, os.Stdout))
+	}
+
 	conf, err := model.LoadConfig(*config)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if err := conf.ApplyEnv(os.LookupEnv); err != nil {
+		log.Fatal(err)
+	}
+
 	if err := conf.Validate(); err != nil {
 		log.Fatal(err)
 	}
 
 	log.Println("successfully loaded configuration")
 
-	mc := NewMQTTClient(conf, postSlackMessage)
-	go updateEntityAndCache(conf, mc)
+	dir := newDirectory()
+
+	n, err := NewNotifier(conf, dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *dryrun {
+		n = dryRunNotifier{n}
+	}
+
+	mc, err := NewMQTTClient(conf, n)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	go updateEntityAndCache(conf, mc, dir)
 
 	log.Println("attempting to connect")
 	go mc.ConnectToMQTTBroker()
 
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 Loop:
 	for {
@@ -71,116 +93,77 @@ Loop:
 				break Loop
 			}
 		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				log.Println("refresh requested from signal", sig)
+				mc.RequestRefresh()
+				continue
+			}
+
 			log.Println("exiting... from signal", sig)
 			break Loop
 		}
 	}
 
+	// The last will is only published when the connection
+	// drops so publish offline ourselves on a clean exit
+	if err := mc.PublishStatus(mc.client, statusOffline); err != nil {
+		log.Println("publish status error:", err)
+	}
+
 	mc.client.Disconnect(0)
+	mc.state.transition(StateDisconnected)
 	close(mc.connCh)
 	close(mc.errCh)
 	close(sigCh)
 }
 
-func postSlackMessage(conf model.SlackConfig, name string) error {
-	msg := conf.Messages[rand.Intn(len(conf.Messages))]
-
-	var channelID string
-
-	for _, u := range slackUsers {
-		if conf.IsBlacklisted(u.Id) {
-			continue
-		}
+func updateEntityAndCache(conf model.Config, mc mqttClient, dir *directory) {
+	l := newSlackLoader(conf.SlackConfig)
+	cs := newCacheStore(conf.SlackConfig.CachePath, conf.SlackConfig.CacheMaxAge.Duration)
+	isSlack := conf.NotifierType() == model.NotifierSlack
 
-		if u != nil &&
-			u.Profile != nil &&
-			u.Profile.RealName == name {
-			channelID = u.Id
-			break
-		}
+	// Load the cache before connecting so names resolve
+	// even when slack can't be reached after a restart
+	if isSlack && cs.enabled() {
+		loadSlackCache(cs, dir)
 	}
 
-	if channelID == "" {
-		msg = "@here " + msg
-		name = strings.ToLower(name)
-
-		for _, c := range slackChannels {
-			if conf.IsBlacklisted(c.Id) {
-				continue
-			}
-
-			if c != nil && strings.ToLower(c.Name) == name {
-				channelID = c.Id
-			}
-		}
-	}
-
-	if channelID == "" {
-		return fmt.Errorf("I found no user or channel called %s", name)
-	}
-
-	logMsg := fmt.Sprintf("Messaging user/channel %q with ID %q", name, channelID)
-	if *dryrun {
-		log.Println("[DRYRUN]", logMsg)
-		return nil
-	}
-
-	log.Println(logMsg)
-	sc := slack.New(conf.Token)
-	return sc.ChatPostMessage(channelID, msg, &slack.ChatPostMessageOpt{
-		LinkNames: "true",
-		Username:  conf.Username,
-		IconEmoji: conf.EmojiIcon,
-	})
-
-	return nil
-}
-
-func updateEntityAndCache(conf model.Config, mc mqttClient) {
-	var err error
-	sc := slack.New(conf.SlackConfig.Token)
 	// Wait for mqtt client to be connected
 	// If its failed the the program will exit
 	connected := <-mc.connCh
 
-	if connected {
-		slackUsers, err = sc.UsersList()
-		if err != nil {
-			log.Println("failed to create slack users cache")
-		}
+	// Only slack users are injected as entities, other
+	// notifiers names are configured on the snips console
+	if connected && isSlack {
+		ei := newEntityInjector(conf.SnipsConfig.InjectedPath)
 
-		slackChannels, err = sc.ChannelsList()
-		if err != nil {
-			log.Println("failed to create slack channels cache")
+		refreshSlackCache(l, cs, dir)
+		updateSlackSlotEntity(mc, ei, dir, conf)
+
+		interval := conf.SlackConfig.RefreshInterval.Duration
+		if interval == 0 {
+			interval = defaultRefreshInterval
 		}
 
-		updateSlackSlotEntity(mc, slackUsers, conf)
-		for range time.Tick(time.Hour * 7) {
-			// Update the users/channels cache
-			users, err := sc.UsersList()
-			if err != nil {
-				log.Println("get slack users failed", err)
-			} else {
-				log.Printf("stored %d users in cache\n", len(users))
-				slackUsers = users
-			}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
 
-			chls, err := sc.ChannelsList()
-			if err != nil {
-				log.Println("get slack channels failed", err)
-			} else {
-				slackChannels = chls
+		for {
+			select {
+			case <-ticker.C:
+			case <-mc.refreshCh:
+				log.Println("refreshing slack cache on request")
 			}
 
-			updateSlackSlotEntity(mc, users, conf)
+			refreshSlackCache(l, cs, dir)
+			updateSlackSlotEntity(mc, ei, dir, conf)
 		}
 	}
 }
 
-func updateSlackSlotEntity(mc mqttClient, users []*slack.User, conf model.Config) {
-	log.Println("publishing new slot values")
-	res := model.BuildEntityFromSlackUsers(conf.SnipsConfig, users)
-	if err := mc.PublishEntity(res); err != nil {
+func updateSlackSlotEntity(mc mqttClient, ei *entityInjector, dir *directory, conf model.Config) {
+	values := entityValues(conf, dir)
+	if err := ei.inject(mc, values); err != nil {
 		log.Println("publish entity error:", err)
 	}
 }
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/bluele/slack"
+)
+
+// Profiles holds the slack profile fields of users keyed by user
+// ID, the vendored profile lacks display_name and custom fields
+type Profiles map[string]map[string]string
+
+// UserAliases returns the spoken aliases of the user from the config
+// keyed by user ID or handle and the alias field of their profile
+func (s SlackConfig) UserAliases(u *slack.User, profiles Profiles) []string {
+	if u == nil {
+		return nil
+	}
+
+	aliases := append([]string(nil), s.Aliases[u.Id]...)
+	aliases = append(aliases, s.Aliases[u.Name]...)
+
+	if s.AliasProfileField == "" {
+		return aliases
+	}
+
+	for _, a := range strings.Split(profiles[u.Id][s.AliasProfileField], ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			aliases = append(aliases, a)
+		}
+	}
+
+	return aliases
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/bluele/slack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSlackConfigUserAliases(t *testing.T) {
+	user := &slack.User{
+		Id:      "U1",
+		Name:    "jtaylor",
+		Profile: &slack.ProfileInfo{RealName: "Jonathan Taylor"},
+	}
+
+	profiles := Profiles{"U1": {"display_name": "Jonny, JT ,"}, "U2": {"display_name": "Alex"}}
+
+	specs := []struct {
+		name   string
+		config SlackConfig
+		want   []string
+	}{
+		{"no aliases", SlackConfig{}, nil},
+		{"by id and handle", SlackConfig{Aliases: map[string][]string{
+			"U1":      {"Jonny"},
+			"jtaylor": {"Taylor"},
+			"U2":      {"Alex"},
+		}}, []string{"Jonny", "Taylor"}},
+		{"from profile field", SlackConfig{AliasProfileField: "display_name"}, []string{"Jonny", "JT"}},
+		{"missing profile field", SlackConfig{AliasProfileField: "pronouns"}, nil},
+	}
+
+	for _, s := range specs {
+		t.Run(s.name, func(t *testing.T) {
+			got := s.config.UserAliases(user, profiles)
+			if !cmp.Equal(s.want, got) {
+				t.Error(cmp.Diff(s.want, got))
+			}
+		})
+	}
+
+	t.Run("nil user", func(t *testing.T) {
+		if got := (SlackConfig{}).UserAliases(nil, profiles); got != nil {
+			t.Errorf("expected no aliases but got %v", got)
+		}
+	})
+}
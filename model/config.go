@@ -1,38 +1,189 @@
 package model
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"os"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported notifier backends
+const (
+	NotifierSlack      = "slack"
+	NotifierWebhook    = "webhook"
+	NotifierMattermost = "mattermost"
 )
 
 // Config holds relevant configuration
 // for MQTT client and future config options
 type Config struct {
-	SlackConfig SlackConfig `json:"slack_config"`
-	SnipsConfig SnipsConfig `json:"snips_config"`
-	MQTTConfig  MQTTConfig  `json:"mqtt_config"`
+	// Notifier selects the backend used to message
+	// users and channels, defaults to slack when empty
+	Notifier string `json:"notifier"`
+
+	SlackConfig      SlackConfig      `json:"slack_config"`
+	WebhookConfig    WebhookConfig    `json:"webhook_config"`
+	MattermostConfig MattermostConfig `json:"mattermost_config"`
+	SnipsConfig      SnipsConfig      `json:"snips_config"`
+	MQTTConfig       MQTTConfig       `json:"mqtt_config"`
 }
 
+// Supported actions an intent can be routed to
+const (
+	// ActionPing messages the user or channel
+	// named in the slot, preferring users
+	ActionPing        = "ping"
+	ActionPingUser    = "ping_user"
+	ActionPingChannel = "ping_channel"
+	// ActionStandupMessage posts the intent's
+	// canned message to the intent's channel
+	ActionStandupMessage = "standup_message"
+	// ActionListAbsent speaks back which of the
+	// intent's members aren't currently active
+	ActionListAbsent = "list_absent"
+)
+
 // SnipsConfig holds snips related
 // configration like intent name
 type SnipsConfig struct {
+	// SlackIntent is routed to the ping action
 	SlackIntent string `json:"slack_intent"`
 	SlotName    string `json:"slot_name"`
+
+	// ChannelSlotName is the entity channel names are injected
+	// into, defaults to the slot name when not set
+	ChannelSlotName string `json:"channel_slot_name"`
+
+	// Intents routes additional intents to actions
+	Intents []IntentConfig `json:"intents"`
+
+	// Minimum intent probability and slot confidence between
+	// 0 and 1, below them the user is asked to confirm the
+	// target before any message is posted. 0 disables them.
+	MinIntentProbability float64 `json:"min_intent_probability"`
+	MinSlotConfidence    float64 `json:"min_slot_confidence"`
+
+	// Optional intents for answering the questions asked
+	// when confirming or disambiguating a target. Without
+	// the confirm and cancel intents the question is
+	// spoken and the session ended.
+	ConfirmIntent string `json:"confirm_intent"`
+	CancelIntent  string `json:"cancel_intent"`
+	// ChoiceIntent answers which of several matches was meant,
+	// the intent which asked the question is always accepted
+	ChoiceIntent string `json:"choice_intent"`
+	// SessionTimeout is how long an answer is waited
+	// for, defaults to 30s when not set
+	SessionTimeout Duration `json:"session_timeout"`
+	// InjectionTimeout is how long an entity injection is waited
+	// on to complete before it's retried, defaults to 5m when not set
+	InjectionTimeout Duration `json:"injection_timeout"`
+
+	// InjectedPath is the file the entity values last injected
+	// are saved to so only changes are injected after a restart
+	InjectedPath string `json:"injected_path"`
+}
+
+// IntentConfig maps an intent name to
+// the action performed when it's received
+type IntentConfig struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+
+	// Channel and Message are required by
+	// the standup message action
+	Channel string `json:"channel,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	// Members holds the spoken names checked
+	// by the list absent action
+	Members []string `json:"members,omitempty"`
 }
 
 type SlackConfig struct {
 	Token string `json:"token"`
 
 	// Message config options
-	Username  string   `json:"username"`
-	EmojiIcon string   `json:"emoji_icon"`
-	Messages  []string `json:"messages"`
+	Username  string `json:"username"`
+	EmojiIcon string `json:"emoji_icon"`
+	// Messages are text/templates rendered with
+	// MessageData, one is picked at random per ping
+	Messages []string `json:"messages" env:"json"`
+	// RichMessages are picked along with Messages
+	// and posted with attachments or blocks
+	RichMessages []RichMessage `json:"rich_messages"`
 
 	// Blacklist holds the list of user/channel IDs
 	// for which should never be messaged.
 	Blacklist []string `json:"blacklist"`
+
+	// ChannelAliases maps a channel name to the additional
+	// names it's spoken as, i.e "devops": ["dev ops"]
+	ChannelAliases map[string][]string `json:"channel_aliases"`
+	// Aliases maps a user ID or handle to the nicknames
+	// they are spoken as, i.e "U012AB3CD": ["Jonny", "JT"]
+	Aliases map[string][]string `json:"user_aliases"`
+	// UsergroupChannel is the channel user groups without
+	// default channels are mentioned in when pinged
+	UsergroupChannel string `json:"usergroup_channel"`
+	// AliasProfileField optionally names a slack profile field,
+	// i.e "display_name", holding comma separated aliases
+	AliasProfileField string `json:"alias_profile_field"`
+
+	// MatchThreshold is the minimum score between 0 and 1
+	// a user must have to match the spoken name
+	MatchThreshold float64 `json:"match_threshold"`
+	// AmbiguityMargin is the score difference under which
+	// two matching users are considered ambiguous
+	AmbiguityMargin float64 `json:"ambiguity_margin"`
+
+	// CachePath is the file users and channels are cached
+	// to so they resolve after a restart, empty disables it
+	CachePath string `json:"cache_path"`
+	// CacheMaxAge ignores a cache older than the age
+	// when loaded at startup, 0 never expires it
+	CacheMaxAge Duration `json:"cache_max_age"`
+	// RefreshInterval is how often users and channels are
+	// refreshed from slack, defaults to 7h when not set
+	RefreshInterval Duration `json:"refresh_interval"`
+
+	// PageLimit is how many users or conversations are
+	// requested per page, defaults to 200 when not set
+	PageLimit int `json:"page_limit"`
+	// ConversationTypes are loaded from conversations.list,
+	// defaults to public_channel, private_channel and mpim
+	ConversationTypes []string `json:"conversation_types"`
+}
+
+// WebhookConfig holds the generic http webhook
+// configuration, the message is posted as json to the URL
+type WebhookConfig struct {
+	URL string `json:"url"`
+	// Optional headers sent with every request
+	Headers  map[string]string `json:"headers"`
+	Messages []string          `json:"messages" env:"json"`
+
+	// Recipients maps spoken names to the target
+	// sent to the webhook. When empty the spoken
+	// name is sent as the target as is.
+	Recipients map[string]string `json:"recipients"`
+}
+
+// MattermostConfig holds the configuration for
+// posting to a mattermost incoming webhook
+type MattermostConfig struct {
+	URL string `json:"url"`
+
+	// Message config options
+	Username  string   `json:"username"`
+	EmojiIcon string   `json:"emoji_icon"`
+	Messages  []string `json:"messages" env:"json"`
+
+	// Recipients maps spoken names to a mattermost
+	// channel name or @username to post to
+	Recipients map[string]string `json:"recipients"`
 }
 
 // MQTTConfig contains the configuration
@@ -46,13 +197,52 @@ type MQTTConfig struct {
 	Username string `json:"username"`
 	// Optional password authentication
 	Password string `json:"password"`
+
+	// Optional TLS options for ssl:// hosts
+	TLS TLSConfig `json:"tls"`
+
+	// ClientID identifies the session to the broker,
+	// required when not using a clean session
+	ClientID string `json:"client_id"`
+	// CleanSession discards subscriptions and queued
+	// messages on disconnect, defaults to true when not set
+	CleanSession *bool `json:"clean_session,omitempty"`
+	// KeepAlive is the interval the broker is pinged at,
+	// defaults to 30s when not set
+	KeepAlive Duration `json:"keep_alive"`
+	// ConnectTimeout is how long each connect attempt
+	// is waited on, defaults to 5s when not set
+	ConnectTimeout Duration `json:"connect_timeout"`
+
+	// Subscribe and publish QoS between 0 and 2,
+	// default to 0 and 1 when not set
+	SubscribeQoS *int `json:"subscribe_qos,omitempty"`
+	PublishQoS   *int `json:"publish_qos,omitempty"`
+
+	// StatusTopic is published online once connected and offline,
+	// as the last will, when the pinger dies unexpectedly
+	StatusTopic string `json:"status_topic"`
+
+	// Optional topic which forces an immediate refresh
+	// of the slack cache when any message is published
+	RefreshTopic string `json:"refresh_topic"`
+
+	// ConnectAttempts is how many times connecting is attempted
+	// before giving up, 0 retries until connected
+	ConnectAttempts int `json:"connect_attempts"`
+	// Delay between connect attempts starting at ConnectBackoff,
+	// default 1s, and doubling up to MaxConnectBackoff, default 2m
+	ConnectBackoff    Duration `json:"connect_backoff"`
+	MaxConnectBackoff Duration `json:"max_connect_backoff"`
 }
 
 func newDefaultConfig() Config {
 	return Config{
+		Notifier: NotifierSlack,
 		SnipsConfig: SnipsConfig{
-			SlackIntent: "username:intent_name",
-			SlotName:    "slack_names",
+			SlackIntent:  "username:intent_name",
+			SlotName:     "slack_names",
+			InjectedPath: "snips_injected.json",
 		},
 		SlackConfig: SlackConfig{
 			Token:     "1234",
@@ -61,69 +251,339 @@ func newDefaultConfig() Config {
 			Messages: []string{
 				"Put your skates on… it’s standup!",
 			},
+			MatchThreshold:  0.8,
+			AmbiguityMargin: 0.05,
+			CachePath:       "slack_cache.json",
+			CacheMaxAge:     Duration{7 * 24 * time.Hour},
+			RefreshInterval: Duration{7 * time.Hour},
 		},
 		MQTTConfig: MQTTConfig{
-			Hosts: []string{"localhost:1833"},
+			Hosts:        []string{"localhost:1833"},
+			RefreshTopic: "snips-slack-pinger/refresh",
 		},
 	}
 }
 
-// GenerateConfig generates a blank
-// json encoded config template
-func GenerateConfig() (string, error) {
-	b, err := json.MarshalIndent(newDefaultConfig(), "", "  ")
+// GenerateConfig generates a blank config
+// template encoded in the format
+func GenerateConfig(format string) (string, error) {
+	b, err := encodeConfig(format, newDefaultConfig())
 	return string(b), err
 }
 
-// LoadConfig loads path and returns config
+// LoadConfig loads path decoding it by the file extension,
+// .yaml/.yml or .toml otherwise json, and returns config
 // instance or returns an error
 func LoadConfig(path string) (Config, error) {
 	var conf Config
 
-	file, err := os.Open(path)
+	b, err := ioutil.ReadFile(path)
 	if err != nil {
 		return conf, err
 	}
 
-	err = json.NewDecoder(file).Decode(&conf)
+	err = decodeConfig(formatFromPath(path), b, &conf)
 
 	return conf, err
 }
 
-// Validate validates the config entries but calling
-// out to other config parts to fill a buffer of errors
-// and returns error if the buf is filled otherwise nil
+// Validate validates the config entries calling out to
+// the other config parts to collect every problem and
+// returns a *ValidationError when any were found
 func (c Config) Validate() error {
-	var buf bytes.Buffer
+	errs := &ValidationError{}
+
+	switch c.NotifierType() {
+	case NotifierSlack:
+		c.SlackConfig.validate(errs)
+	case NotifierWebhook:
+		c.WebhookConfig.validate(errs)
+	case NotifierMattermost:
+		c.MattermostConfig.validate(errs)
+	default:
+		errs.add("notifier", "unknown notifier %q", c.Notifier)
+	}
 
-	c.SlackConfig.validate(&buf)
-	c.SnipsConfig.validate(&buf)
+	c.SnipsConfig.validate(errs)
+	c.MQTTConfig.validate(errs)
 
-	if buf.Len() > 0 {
-		return fmt.Errorf("Following error(s) with config:\n%s", buf.String())
+	if len(errs.Errors) > 0 {
+		return errs
 	}
 
 	return nil
 }
 
-func (s SlackConfig) validate(buf *bytes.Buffer) {
+func (s SlackConfig) validate(errs *ValidationError) {
 	if s.Token == "" {
-		buf.WriteString(" - slack token required")
+		errs.add("slack_config.token", "slack token required")
+	}
+
+	if len(s.Messages) == 0 && len(s.RichMessages) == 0 {
+		errs.add("slack_config.messages", "at least one slack message required")
+	}
+
+	validateMessages(errs, "slack_config.messages", s.Messages)
+
+	for i, m := range s.RichMessages {
+		m.validate(errs, fmt.Sprintf("slack_config.rich_messages[%d]", i))
+	}
+
+	if s.MatchThreshold < 0 || s.MatchThreshold > 1 {
+		errs.add("slack_config.match_threshold", "slack match threshold must be between 0 and 1")
+	}
+
+	if s.AmbiguityMargin < 0 || s.AmbiguityMargin > 1 {
+		errs.add("slack_config.ambiguity_margin", "slack ambiguity margin must be between 0 and 1")
+	}
+
+	if s.CacheMaxAge.Duration < 0 {
+		errs.add("slack_config.cache_max_age", "slack cache max age must be positive")
+	}
+
+	if s.RefreshInterval.Duration < 0 {
+		errs.add("slack_config.refresh_interval", "slack refresh interval must be positive")
+	}
+
+	if s.PageLimit < 0 || s.PageLimit > 1000 {
+		errs.add("slack_config.page_limit", "slack page limit must be between 0 and 1000")
+	}
+
+	for i, ct := range s.ConversationTypes {
+		switch ct {
+		case "public_channel", "private_channel", "mpim":
+		default:
+			errs.add(fmt.Sprintf("slack_config.conversation_types[%d]", i),
+				"slack conversation type %q must be public_channel, private_channel or mpim", ct)
+		}
+	}
+}
+
+func (w WebhookConfig) validate(errs *ValidationError) {
+	if w.URL == "" {
+		errs.add("webhook_config.url", "webhook url required")
+	}
+
+	if len(w.Messages) == 0 {
+		errs.add("webhook_config.messages", "at least one webhook message required")
+	}
+
+	validateMessages(errs, "webhook_config.messages", w.Messages)
+}
+
+func (m MattermostConfig) validate(errs *ValidationError) {
+	if m.URL == "" {
+		errs.add("mattermost_config.url", "mattermost url required")
+	}
+
+	if len(m.Messages) == 0 {
+		errs.add("mattermost_config.messages", "at least one mattermost message required")
 	}
 
-	if len(s.Messages) == 0 {
-		buf.WriteString(" - at least one slack message required")
+	validateMessages(errs, "mattermost_config.messages", m.Messages)
+
+	if len(m.Recipients) == 0 {
+		errs.add("mattermost_config.recipients", "at least one mattermost recipient required")
 	}
 }
 
-func (s SnipsConfig) validate(buf *bytes.Buffer) {
-	if s.SlackIntent == "" {
-		buf.WriteString(" - snips slack intent required")
+func (s SnipsConfig) validate(errs *ValidationError) {
+	if s.SlackIntent == "" && len(s.Intents) == 0 {
+		errs.add("snips_config.slack_intent", "snips slack intent required")
+	}
+
+	for i, ic := range s.Intents {
+		ic.validate(errs, i)
+	}
+
+	if s.MinIntentProbability < 0 || s.MinIntentProbability > 1 {
+		errs.add("snips_config.min_intent_probability", "snips min intent probability must be between 0 and 1")
+	}
+
+	if s.MinSlotConfidence < 0 || s.MinSlotConfidence > 1 {
+		errs.add("snips_config.min_slot_confidence", "snips min slot confidence must be between 0 and 1")
+	}
+
+	switch {
+	case (s.ConfirmIntent == "") != (s.CancelIntent == ""):
+		errs.add("snips_config.cancel_intent", "snips confirm and cancel intents must be set together")
+	case (s.MinIntentProbability > 0 || s.MinSlotConfidence > 0) && !s.CanConfirm():
+		// Without a yes/no answer a low confidence name is
+		// asked about every time and can never be pinged
+		errs.add("snips_config.confirm_intent", "snips confirm and cancel intents required with a min intent probability or slot confidence")
+	}
+
+	if s.SessionTimeout.Duration < 0 {
+		errs.add("snips_config.session_timeout", "snips session timeout must be positive")
+	}
+
+	if s.InjectionTimeout.Duration < 0 {
+		errs.add("snips_config.injection_timeout", "snips injection timeout must be positive")
 	}
 
 	if s.SlotName == "" {
-		buf.WriteString(" - snips slot name required")
+		errs.add("snips_config.slot_name", "snips slot name required")
+	}
+}
+
+func (ic IntentConfig) validate(errs *ValidationError, i int) {
+	field := fmt.Sprintf("snips_config.intents[%d]", i)
+
+	if ic.Name == "" {
+		errs.add(field+".name", "snips intent %d name required", i)
 	}
+
+	switch ic.Action {
+	case ActionPing, ActionPingUser, ActionPingChannel:
+	case ActionStandupMessage:
+		if ic.Channel == "" || ic.Message == "" {
+			errs.add(field+".message", "snips intent %d channel and message required", i)
+		}
+
+		validateMessage(errs, field+".message", ic.Message)
+	case ActionListAbsent:
+		if len(ic.Members) == 0 {
+			errs.add(field+".members", "snips intent %d at least one member required", i)
+		}
+	default:
+		errs.add(field+".action", "snips intent %d unknown action %q", i, ic.Action)
+	}
+}
+
+func (m MQTTConfig) validate(errs *ValidationError) {
+	if len(m.Hosts) == 0 {
+		errs.add("mqtt_config.host", "mqtt host required")
+	}
+
+	for i, h := range m.Hosts {
+		validateHost(errs, i, h)
+	}
+
+	// A password can't be sent without a username
+	// but a username can be sent on its own
+	if m.Password != "" && m.Username == "" {
+		errs.add("mqtt_config.username", "mqtt username required with a password")
+	}
+
+	m.TLS.validate(errs)
+
+	if !m.IsCleanSession() && m.ClientID == "" {
+		errs.add("mqtt_config.client_id", "mqtt client id required without a clean session")
+	}
+
+	if m.KeepAlive.Duration < 0 {
+		errs.add("mqtt_config.keep_alive", "mqtt keep alive must be positive")
+	}
+
+	if m.ConnectTimeout.Duration < 0 {
+		errs.add("mqtt_config.connect_timeout", "mqtt connect timeout must be positive")
+	}
+
+	if q := m.SubscribeQoS; q != nil && (*q < 0 || *q > 2) {
+		errs.add("mqtt_config.subscribe_qos", "mqtt subscribe qos must be between 0 and 2")
+	}
+
+	if q := m.PublishQoS; q != nil && (*q < 0 || *q > 2) {
+		errs.add("mqtt_config.publish_qos", "mqtt publish qos must be between 0 and 2")
+	}
+}
+
+// validateHost validates the i'th broker host, hosts without
+// a scheme are tcp as they are when connecting
+func validateHost(errs *ValidationError, i int, h string) {
+	field := fmt.Sprintf("mqtt_config.host[%d]", i)
+
+	if !strings.Contains(h, "://") {
+		h = "tcp://" + h
+	}
+
+	u, err := url.Parse(h)
+	if err != nil {
+		errs.add(field, "mqtt host %d %q invalid", i, h)
+		return
+	}
+
+	switch u.Scheme {
+	case "tcp", "ssl", "ws", "wss":
+	default:
+		errs.add(field, "mqtt host %d scheme %q must be tcp, ssl, ws or wss", i, u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		errs.add(field, "mqtt host %d name required", i)
+	}
+
+	if p := u.Port(); p != "" {
+		if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+			errs.add(field, "mqtt host %d port must be between 1 and 65535", i)
+		}
+	}
+}
+
+// Routes returns every intent which should be subscribed
+// to, including the slack intent routed to the ping action
+func (s SnipsConfig) Routes() []IntentConfig {
+	var routes []IntentConfig
+
+	if s.SlackIntent != "" {
+		routes = append(routes, IntentConfig{Name: s.SlackIntent, Action: ActionPing})
+	}
+
+	return append(routes, s.Intents...)
+}
+
+// Route returns the intent config for
+// the intent name and if it was found
+func (s SnipsConfig) Route(name string) (IntentConfig, bool) {
+	for _, r := range s.Routes() {
+		if r.Name == name {
+			return r, true
+		}
+	}
+
+	return IntentConfig{}, false
+}
+
+// IsIntentUncertain reports whether the intent's
+// probability is below the configured minimum
+func (s SnipsConfig) IsIntentUncertain(i Intent) bool {
+	return i.Probability < s.MinIntentProbability
+}
+
+// IsSlotUncertain reports whether the slot's
+// confidence is below the configured minimum
+func (s SnipsConfig) IsSlotUncertain(sl Slot) bool {
+	return sl.Confidence < s.MinSlotConfidence
+}
+
+// ChannelSlot returns the entity channel names
+// are injected into defaulting to the slot name
+func (s SnipsConfig) ChannelSlot() string {
+	if s.ChannelSlotName == "" {
+		return s.SlotName
+	}
+
+	return s.ChannelSlotName
+}
+
+// CanConfirm reports whether the confirm and cancel
+// intents are configured for yes/no answers
+func (s SnipsConfig) CanConfirm() bool {
+	return s.ConfirmIntent != "" && s.CancelIntent != ""
+}
+
+// DialogueIntents returns the configured intents
+// used to answer questions asked by the pinger
+func (s SnipsConfig) DialogueIntents() []string {
+	var intents []string
+
+	for _, i := range []string{s.ConfirmIntent, s.CancelIntent, s.ChoiceIntent} {
+		if i != "" {
+			intents = append(intents, i)
+		}
+	}
+
+	return intents
 }
 
 func (s SlackConfig) IsBlacklisted(id string) bool {
@@ -135,3 +595,60 @@ func (s SlackConfig) IsBlacklisted(id string) bool {
 
 	return false
 }
+
+// IsCleanSession reports whether a clean
+// session is requested, true when not set
+func (m MQTTConfig) IsCleanSession() bool {
+	return m.CleanSession == nil || *m.CleanSession
+}
+
+// SubQoS returns the QoS of subscriptions, 0 when not set
+func (m MQTTConfig) SubQoS() byte {
+	if m.SubscribeQoS == nil {
+		return 0
+	}
+
+	return byte(*m.SubscribeQoS)
+}
+
+// PubQoS returns the QoS of published messages, 1 when not set
+func (m MQTTConfig) PubQoS() byte {
+	if m.PublishQoS == nil {
+		return 1
+	}
+
+	return byte(*m.PublishQoS)
+}
+
+// NotifierType returns the configured notifier
+// backend defaulting to slack when not set
+func (c Config) NotifierType() string {
+	if c.Notifier == "" {
+		return NotifierSlack
+	}
+
+	return c.Notifier
+}
+
+// RichMessages returns the rich messages configured for
+// the selected notifier backend, only slack supports them
+func (c Config) RichMessages() []RichMessage {
+	if c.NotifierType() != NotifierSlack {
+		return nil
+	}
+
+	return c.SlackConfig.RichMessages
+}
+
+// Messages returns the messages configured
+// for the selected notifier backend
+func (c Config) Messages() []string {
+	switch c.NotifierType() {
+	case NotifierWebhook:
+		return c.WebhookConfig.Messages
+	case NotifierMattermost:
+		return c.MattermostConfig.Messages
+	default:
+		return c.SlackConfig.Messages
+	}
+}
@@ -6,17 +6,19 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
 
 func TestGenerateConfig(t *testing.T) {
-	got, err := GenerateConfig()
+	got, err := GenerateConfig(FormatJSON)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	wc := Config{
+		Notifier: NotifierSlack,
 		SlackConfig: SlackConfig{
 			Token:     "1234",
 			Username:  "Standup bot",
@@ -24,13 +26,20 @@ func TestGenerateConfig(t *testing.T) {
 			Messages: []string{
 				"Put your skates on… it’s standup!",
 			},
+			MatchThreshold:  0.8,
+			AmbiguityMargin: 0.05,
+			CachePath:       "slack_cache.json",
+			CacheMaxAge:     Duration{7 * 24 * time.Hour},
+			RefreshInterval: Duration{7 * time.Hour},
 		},
 		SnipsConfig: SnipsConfig{
-			SlackIntent: "username:intent_name",
-			SlotName:    "slack_names",
+			SlackIntent:  "username:intent_name",
+			SlotName:     "slack_names",
+			InjectedPath: "snips_injected.json",
 		},
 		MQTTConfig: MQTTConfig{
-			Hosts: []string{"localhost:1833"},
+			Hosts:        []string{"localhost:1833"},
+			RefreshTopic: "snips-slack-pinger/refresh",
 		},
 	}
 
@@ -42,7 +51,7 @@ func TestGenerateConfig(t *testing.T) {
 	want := string(b)
 
 	if got != want {
-		t.Fatalf(cmp.Diff(got, want))
+		t.Fatal(cmp.Diff(got, want))
 	}
 }
 
@@ -137,15 +146,15 @@ func TestConfigValidate(t *testing.T) {
 			t.Fatal("expected error but got none")
 		}
 
-		want := "Following error(s) with config:\n" +
-			" - slack token required" +
-			" - at least one slack message required" +
-			" - snips slack intent required" +
-			" - snips slot name required"
-
-		if got.Error() != want {
-			t.Fatal(cmp.Diff(want, got))
+		want := []FieldError{
+			{"slack_config.token", "slack token required"},
+			{"slack_config.messages", "at least one slack message required"},
+			{"snips_config.slack_intent", "snips slack intent required"},
+			{"snips_config.slot_name", "snips slot name required"},
+			{"mqtt_config.host", "mqtt host required"},
 		}
+
+		assertValidationError(t, got, want)
 	})
 
 	t.Run("when some of config invalid", func(t *testing.T) {
@@ -160,14 +169,297 @@ func TestConfigValidate(t *testing.T) {
 			t.Fatal("expected error but got none")
 		}
 
-		want := "Following error(s) with config:\n" +
-			" - at least one slack message required" +
-			" - snips slack intent required" +
-			" - snips slot name required"
+		want := []FieldError{
+			{"slack_config.messages", "at least one slack message required"},
+			{"snips_config.slack_intent", "snips slack intent required"},
+			{"snips_config.slot_name", "snips slot name required"},
+			{"mqtt_config.host", "mqtt host required"},
+		}
+
+		assertValidationError(t, got, want)
+	})
+
+	t.Run("when mqtt session options invalid", func(t *testing.T) {
+		clean, qos := false, 3
+		conf := Config{
+			SlackConfig: SlackConfig{
+				Token:    "1234",
+				Messages: []string{"Standup!"},
+			},
+			SnipsConfig: SnipsConfig{
+				SlackIntent: "username:intent_name",
+				SlotName:    "slack_names",
+			},
+			MQTTConfig: MQTTConfig{
+				Hosts:          []string{"localhost:1883"},
+				CleanSession:   &clean,
+				KeepAlive:      Duration{-time.Second},
+				ConnectTimeout: Duration{-time.Second},
+				SubscribeQoS:   &qos,
+				PublishQoS:     &qos,
+			},
+		}
+
+		got := conf.Validate()
+		if got == nil {
+			t.Fatal("expected error but got none")
+		}
 
-		if got.Error() != want {
-			t.Fatal(cmp.Diff(want, got))
+		want := []FieldError{
+			{"mqtt_config.client_id", "mqtt client id required without a clean session"},
+			{"mqtt_config.keep_alive", "mqtt keep alive must be positive"},
+			{"mqtt_config.connect_timeout", "mqtt connect timeout must be positive"},
+			{"mqtt_config.subscribe_qos", "mqtt subscribe qos must be between 0 and 2"},
+			{"mqtt_config.publish_qos", "mqtt publish qos must be between 0 and 2"},
 		}
+
+		assertValidationError(t, got, want)
+	})
+
+	t.Run("when message templates invalid", func(t *testing.T) {
+		conf := Config{
+			SlackConfig: SlackConfig{
+				Token:    "1234",
+				Messages: []string{"Standup {{.FirstName}}!", "Standup {{.Nickname}}!"},
+			},
+			SnipsConfig: SnipsConfig{
+				SlotName: "slack_names",
+				Intents: []IntentConfig{
+					{Name: "user:standup", Action: ActionStandupMessage, Channel: "general", Message: "{{if}}"},
+				},
+			},
+			MQTTConfig: MQTTConfig{Hosts: []string{"localhost:1883"}},
+		}
+
+		want := []FieldError{
+			{"slack_config.messages[1]", `invalid message template: template: message:1:10: executing "message" at <.Nickname>: can't evaluate field Nickname in type model.MessageData`},
+			{"snips_config.intents[0].message", "invalid message template: template: message:1: missing value for if"},
+		}
+
+		assertValidationError(t, conf.Validate(), want)
+	})
+
+	t.Run("when slack directory options invalid", func(t *testing.T) {
+		conf := Config{
+			SlackConfig: SlackConfig{
+				Token:             "1234",
+				Messages:          []string{"Standup!"},
+				PageLimit:         1001,
+				ConversationTypes: []string{"public_channel", "im"},
+			},
+			SnipsConfig: SnipsConfig{SlackIntent: "username:intent_name", SlotName: "slack_names"},
+			MQTTConfig:  MQTTConfig{Hosts: []string{"localhost:1883"}},
+		}
+
+		assertValidationError(t, conf.Validate(), []FieldError{
+			{"slack_config.page_limit", "slack page limit must be between 0 and 1000"},
+			{"slack_config.conversation_types[1]", `slack conversation type "im" must be public_channel, private_channel or mpim`},
+		})
+	})
+
+	t.Run("when mqtt hosts and credentials invalid", func(t *testing.T) {
+		conf := Config{
+			SlackConfig: SlackConfig{
+				Token:    "1234",
+				Messages: []string{"Standup!"},
+			},
+			SnipsConfig: SnipsConfig{
+				SlackIntent: "username:intent_name",
+				SlotName:    "slack_names",
+			},
+			MQTTConfig: MQTTConfig{
+				Hosts: []string{
+					"localhost:1883",
+					"tcp://localhost",
+					"ssl://broker.local:8883",
+					"wss://broker.local/mqtt",
+					"http://example.com:1883",
+					"tcp://localhost:0",
+					"localhost:65536",
+					"tcp://:1883",
+					"tcp://local host",
+				},
+				Password: "pass",
+			},
+		}
+
+		got := conf.Validate()
+		if got == nil {
+			t.Fatal("expected error but got none")
+		}
+
+		want := []FieldError{
+			{"mqtt_config.host[4]", `mqtt host 4 scheme "http" must be tcp, ssl, ws or wss`},
+			{"mqtt_config.host[5]", "mqtt host 5 port must be between 1 and 65535"},
+			{"mqtt_config.host[6]", "mqtt host 6 port must be between 1 and 65535"},
+			{"mqtt_config.host[7]", "mqtt host 7 name required"},
+			{"mqtt_config.host[8]", `mqtt host 8 "tcp://local host" invalid`},
+			{"mqtt_config.username", "mqtt username required with a password"},
+		}
+
+		assertValidationError(t, got, want)
+	})
+
+	t.Run("when thresholds out of range", func(t *testing.T) {
+		conf := Config{
+			SlackConfig: SlackConfig{
+				Token:           "1234",
+				Messages:        []string{"Standup!"},
+				MatchThreshold:  1.5,
+				AmbiguityMargin: -0.1,
+				CacheMaxAge:     Duration{-time.Hour},
+				RefreshInterval: Duration{-time.Hour},
+			},
+			SnipsConfig: SnipsConfig{
+				SlackIntent:          "username:intent_name",
+				SlotName:             "slack_names",
+				MinIntentProbability: 2,
+				MinSlotConfidence:    -1,
+				ConfirmIntent:        "yes",
+				SessionTimeout:       Duration{-time.Second},
+				InjectionTimeout:     Duration{-time.Second},
+			},
+			MQTTConfig: MQTTConfig{Hosts: []string{"localhost:1883"}},
+		}
+
+		got := conf.Validate()
+		if got == nil {
+			t.Fatal("expected error but got none")
+		}
+
+		want := []FieldError{
+			{"slack_config.match_threshold", "slack match threshold must be between 0 and 1"},
+			{"slack_config.ambiguity_margin", "slack ambiguity margin must be between 0 and 1"},
+			{"slack_config.cache_max_age", "slack cache max age must be positive"},
+			{"slack_config.refresh_interval", "slack refresh interval must be positive"},
+			{"snips_config.min_intent_probability", "snips min intent probability must be between 0 and 1"},
+			{"snips_config.min_slot_confidence", "snips min slot confidence must be between 0 and 1"},
+			{"snips_config.cancel_intent", "snips confirm and cancel intents must be set together"},
+			{"snips_config.session_timeout", "snips session timeout must be positive"},
+			{"snips_config.injection_timeout", "snips injection timeout must be positive"},
+		}
+
+		assertValidationError(t, got, want)
+	})
+
+	t.Run("when thresholds set without confirm intents", func(t *testing.T) {
+		conf := Config{
+			SlackConfig: SlackConfig{Token: "1234", Messages: []string{"Standup!"}},
+			SnipsConfig: SnipsConfig{
+				SlackIntent:       "username:intent_name",
+				SlotName:          "slack_names",
+				MinSlotConfidence: 0.5,
+			},
+			MQTTConfig: MQTTConfig{Hosts: []string{"localhost:1883"}},
+		}
+
+		want := []FieldError{
+			{"snips_config.confirm_intent", "snips confirm and cancel intents required with a min intent probability or slot confidence"},
+		}
+
+		assertValidationError(t, conf.Validate(), want)
+	})
+
+	t.Run("when webhook config invalid", func(t *testing.T) {
+		conf := Config{
+			Notifier: NotifierWebhook,
+			SnipsConfig: SnipsConfig{
+				SlackIntent: "username:intent_name",
+				SlotName:    "slack_names",
+			},
+			MQTTConfig: MQTTConfig{Hosts: []string{"localhost:1883"}},
+		}
+
+		got := conf.Validate()
+		if got == nil {
+			t.Fatal("expected error but got none")
+		}
+
+		want := []FieldError{
+			{"webhook_config.url", "webhook url required"},
+			{"webhook_config.messages", "at least one webhook message required"},
+		}
+
+		assertValidationError(t, got, want)
+	})
+
+	t.Run("when mattermost config invalid", func(t *testing.T) {
+		conf := Config{
+			Notifier: NotifierMattermost,
+			SnipsConfig: SnipsConfig{
+				SlackIntent: "username:intent_name",
+				SlotName:    "slack_names",
+			},
+			MQTTConfig: MQTTConfig{Hosts: []string{"localhost:1883"}},
+		}
+
+		got := conf.Validate()
+		if got == nil {
+			t.Fatal("expected error but got none")
+		}
+
+		want := []FieldError{
+			{"mattermost_config.url", "mattermost url required"},
+			{"mattermost_config.messages", "at least one mattermost message required"},
+			{"mattermost_config.recipients", "at least one mattermost recipient required"},
+		}
+
+		assertValidationError(t, got, want)
+	})
+
+	t.Run("when notifier unknown", func(t *testing.T) {
+		conf := Config{
+			Notifier: "irc",
+			SnipsConfig: SnipsConfig{
+				SlackIntent: "username:intent_name",
+				SlotName:    "slack_names",
+			},
+			MQTTConfig: MQTTConfig{Hosts: []string{"localhost:1883"}},
+		}
+
+		got := conf.Validate()
+		if got == nil {
+			t.Fatal("expected error but got none")
+		}
+
+		want := []FieldError{
+			{"notifier", `unknown notifier "irc"`},
+		}
+
+		assertValidationError(t, got, want)
+	})
+
+	t.Run("when snips intents invalid", func(t *testing.T) {
+		conf := Config{
+			SlackConfig: SlackConfig{
+				Token:    "1234",
+				Messages: []string{"Standup!"},
+			},
+			SnipsConfig: SnipsConfig{
+				SlotName: "slack_names",
+				Intents: []IntentConfig{
+					{Action: ActionPing},
+					{Name: "standup", Action: ActionStandupMessage},
+					{Name: "absent", Action: ActionListAbsent},
+					{Name: "other", Action: "dance"},
+				},
+			},
+			MQTTConfig: MQTTConfig{Hosts: []string{"localhost:1883"}},
+		}
+
+		got := conf.Validate()
+		if got == nil {
+			t.Fatal("expected error but got none")
+		}
+
+		want := []FieldError{
+			{"snips_config.intents[0].name", "snips intent 0 name required"},
+			{"snips_config.intents[1].message", "snips intent 1 channel and message required"},
+			{"snips_config.intents[2].members", "snips intent 2 at least one member required"},
+			{"snips_config.intents[3].action", `snips intent 3 unknown action "dance"`},
+		}
+
+		assertValidationError(t, got, want)
 	})
 
 	t.Run("when config all valid", func(t *testing.T) {
@@ -182,6 +474,7 @@ func TestConfigValidate(t *testing.T) {
 				SlackIntent: "username:intent_name",
 				SlotName:    "slack_names",
 			},
+			MQTTConfig: MQTTConfig{Hosts: []string{"localhost:1883"}},
 		}
 
 		err := conf.Validate()
@@ -191,6 +484,118 @@ func TestConfigValidate(t *testing.T) {
 	})
 }
 
+func TestConfigMessages(t *testing.T) {
+	conf := Config{
+		SlackConfig:      SlackConfig{Messages: []string{"slack"}},
+		WebhookConfig:    WebhookConfig{Messages: []string{"webhook"}},
+		MattermostConfig: MattermostConfig{Messages: []string{"mattermost"}},
+	}
+
+	specs := []struct {
+		notifier string
+		want     string
+	}{
+		{"", "slack"},
+		{NotifierSlack, "slack"},
+		{NotifierWebhook, "webhook"},
+		{NotifierMattermost, "mattermost"},
+	}
+
+	for _, s := range specs {
+		conf.Notifier = s.notifier
+
+		got := conf.Messages()
+		if len(got) != 1 || got[0] != s.want {
+			t.Errorf("expected messages [%s] but got %v", s.want, got)
+		}
+	}
+}
+
+func TestSnipsConfigRoute(t *testing.T) {
+	conf := SnipsConfig{
+		SlackIntent: "slack-intent",
+		Intents: []IntentConfig{
+			{Name: "standup-intent", Action: ActionStandupMessage},
+		},
+	}
+
+	specs := []struct {
+		in     string
+		want   IntentConfig
+		wantOk bool
+	}{
+		{"slack-intent", IntentConfig{Name: "slack-intent", Action: ActionPing}, true},
+		{"standup-intent", IntentConfig{Name: "standup-intent", Action: ActionStandupMessage}, true},
+		{"other-intent", IntentConfig{}, false},
+	}
+
+	for _, s := range specs {
+		got, ok := conf.Route(s.in)
+		if ok != s.wantOk {
+			t.Errorf("expected found %t but got %t", s.wantOk, ok)
+		}
+
+		if !cmp.Equal(s.want, got) {
+			t.Error(cmp.Diff(s.want, got))
+		}
+	}
+}
+
+func TestSnipsConfigUncertainty(t *testing.T) {
+	conf := SnipsConfig{MinIntentProbability: 0.7, MinSlotConfidence: 0.5}
+
+	if !conf.IsIntentUncertain(Intent{Probability: 0.69}) {
+		t.Error("expected intent below minimum to be uncertain")
+	}
+
+	if conf.IsIntentUncertain(Intent{Probability: 0.7}) {
+		t.Error("expected intent at minimum to be certain")
+	}
+
+	if !conf.IsSlotUncertain(Slot{Confidence: 0.4}) {
+		t.Error("expected slot below minimum to be uncertain")
+	}
+
+	if (SnipsConfig{}).IsSlotUncertain(Slot{}) {
+		t.Error("expected no minimum to never be uncertain")
+	}
+}
+
+func TestSnipsConfigChannelSlot(t *testing.T) {
+	if got := (SnipsConfig{SlotName: "names"}).ChannelSlot(); got != "names" {
+		t.Errorf("expected slot name but got %q", got)
+	}
+
+	conf := SnipsConfig{SlotName: "names", ChannelSlotName: "channels"}
+	if got := conf.ChannelSlot(); got != "channels" {
+		t.Errorf("expected channel slot name but got %q", got)
+	}
+}
+
+func TestSnipsConfigDialogueIntents(t *testing.T) {
+	conf := SnipsConfig{ConfirmIntent: "yes", CancelIntent: "no"}
+
+	if !conf.CanConfirm() {
+		t.Error("expected to be able to confirm")
+	}
+
+	want := []string{"yes", "no"}
+	if got := conf.DialogueIntents(); !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+
+	conf = SnipsConfig{ChoiceIntent: "choice"}
+
+	if conf.CanConfirm() {
+		t.Error("expected not to be able to confirm")
+	}
+
+	want = []string{"choice"}
+	if got := conf.DialogueIntents(); !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
 func TestSlackConfigIsBlacklisted(t *testing.T) {
 	specs := []struct {
 		config SlackConfig
@@ -210,3 +615,29 @@ func TestSlackConfigIsBlacklisted(t *testing.T) {
 		}
 	}
 }
+
+func TestMQTTConfigSessionDefaults(t *testing.T) {
+	var m MQTTConfig
+	if !m.IsCleanSession() || m.SubQoS() != 0 || m.PubQoS() != 1 {
+		t.Errorf("expected clean session, qos 0 and 1 but got %t, %d and %d", m.IsCleanSession(), m.SubQoS(), m.PubQoS())
+	}
+
+	clean, sub, pub := false, 1, 0
+	m = MQTTConfig{CleanSession: &clean, SubscribeQoS: &sub, PublishQoS: &pub}
+	if m.IsCleanSession() || m.SubQoS() != 1 || m.PubQoS() != 0 {
+		t.Errorf("expected persistent session, qos 1 and 0 but got %t, %d and %d", m.IsCleanSession(), m.SubQoS(), m.PubQoS())
+	}
+}
+
+func assertValidationError(t *testing.T, got error, want []FieldError) {
+	t.Helper()
+
+	ve, ok := got.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected *ValidationError but got %T", got)
+	}
+
+	if !cmp.Equal(want, ve.Errors) {
+		t.Fatal(cmp.Diff(want, ve.Errors))
+	}
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDurationJSON(t *testing.T) {
+	t.Run("marshal", func(t *testing.T) {
+		b, err := json.Marshal(Duration{30 * time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != `"30s"` {
+			t.Errorf("expected %q but got %q", `"30s"`, b)
+		}
+	})
+
+	specs := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{`"7h"`, 7 * time.Hour, false},
+		{`"1m30s"`, 90 * time.Second, false},
+		{`""`, 0, false},
+		{`"soon"`, 0, true},
+		{`30`, 0, true},
+	}
+
+	for _, s := range specs {
+		var d Duration
+		err := json.Unmarshal([]byte(s.in), &d)
+
+		if (err != nil) != s.wantErr {
+			t.Errorf("expected error %t for %s but got %v", s.wantErr, s.in, err)
+		}
+
+		if d.Duration != s.want {
+			t.Errorf("expected %s for %s but got %s", s.want, s.in, d.Duration)
+		}
+	}
+}
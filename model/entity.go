@@ -1,43 +1,129 @@
 package model
 
 import (
+	"strings"
+
 	"github.com/bluele/slack"
 )
 
 // Entity contains operations/data for
 // injecting entities via mqtt message
 type Entity struct {
+	// ID is echoed back as the request ID
+	// when the injection is complete
+	ID  string          `json:"id,omitempty"`
 	Ops [][]interface{} `json:"operations"`
 }
 
 func BuildEntityFromSlackUsers(c SnipsConfig, users []*slack.User) *Entity {
-	var entries []string
+	return BuildEntity(Config{SnipsConfig: c}, users, nil)
+}
 
-	if len(users) == 0 {
-		return nil
-	}
+// BuildEntity builds the entity injecting every entity value
+// from scratch, returns nil when there is nothing to inject
+func BuildEntity(c Config, users []*slack.User, channels []*slack.Channel) *Entity {
+	return DiffEntity(nil, EntityValues(c, users, nil, channels))
+}
 
-	ei := Entity{Ops: make([][]interface{}, 0)}
+// EntityValues returns the values of each slot, user real names and
+// aliases, read from profiles too, into the slot and channel names
+// with their spoken aliases into the channel slot
+func EntityValues(c Config, users []*slack.User, profiles Profiles, channels []*slack.Channel) map[string][]string {
+	values := map[string][]string{}
 
 	for _, u := range users {
 		if u == nil || u.Deleted || u.Profile == nil {
 			continue
 		}
 
-		entries = append(entries, u.Profile.RealName)
+		values[c.SnipsConfig.SlotName] = append(values[c.SnipsConfig.SlotName], u.Profile.RealName)
+		values[c.SnipsConfig.SlotName] = append(values[c.SnipsConfig.SlotName], c.SlackConfig.UserAliases(u, profiles)...)
+	}
+
+	slot := c.SnipsConfig.ChannelSlot()
+	for _, ch := range channels {
+		if ch == nil || ch.IsArchived {
+			continue
+		}
+
+		values[slot] = append(values[slot], ChannelSpokenNames(ch.Name, c.SlackConfig.ChannelAliases)...)
 	}
 
-	if len(entries) == 0 {
+	return values
+}
+
+// DiffEntity builds the entity changing the injected values into
+// next. New values are added, when any are removed every slot is
+// reset and reinjected. Returns nil when nothing has changed.
+func DiffEntity(injected, next map[string][]string) *Entity {
+	added := map[string][]string{}
+	removed := false
+
+	for slot, vs := range next {
+		have := valueSet(injected[slot])
+		for _, v := range vs {
+			if !have[v] {
+				added[slot] = append(added[slot], v)
+				have[v] = true
+			}
+		}
+	}
+
+	for slot, vs := range injected {
+		want := valueSet(next[slot])
+		for _, v := range vs {
+			if !want[v] {
+				removed = true
+			}
+		}
+	}
+
+	if removed {
+		values := map[string][]string{}
+		for slot := range injected {
+			values[slot] = []string{}
+		}
+
+		for slot, vs := range next {
+			values[slot] = vs
+		}
+
+		return &Entity{Ops: [][]interface{}{{"addFromVanilla", values}}}
+	}
+
+	if len(added) == 0 {
 		return nil
 	}
 
-	val := []interface{}{
-		"addFromVanilla",
-		map[string][]string{
-			c.SlotName: entries,
-		},
+	// Nothing was injected before so start from vanilla
+	if len(injected) == 0 {
+		return &Entity{Ops: [][]interface{}{{"addFromVanilla", added}}}
+	}
+
+	return &Entity{Ops: [][]interface{}{{"add", added}}}
+}
+
+func valueSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+
+	return set
+}
+
+// ChannelSpokenNames returns the names a channel can be spoken as,
+// its name, the name with separators as spaces and any aliases
+func ChannelSpokenNames(name string, aliases map[string][]string) []string {
+	names := []string{name}
+
+	spoken := strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	}), " ")
+
+	if spoken != name && spoken != "" {
+		names = append(names, spoken)
 	}
 
-	ei.Ops = append(ei.Ops, val)
-	return &ei
+	return append(names, aliases[name]...)
 }
@@ -102,3 +102,188 @@ func TestBuildEntityFromSlackUsers(t *testing.T) {
 		}
 	})
 }
+
+func TestBuildEntity(t *testing.T) {
+	users := []*slack.User{
+		{Profile: &slack.ProfileInfo{RealName: "Jodie Foster"}},
+	}
+
+	channels := []*slack.Channel{
+		{Name: "devops"},
+		{Name: "team-backend"},
+		{Name: "old-team", IsArchived: true},
+		nil,
+	}
+
+	conf := Config{
+		SlackConfig: SlackConfig{
+			ChannelAliases: map[string][]string{"devops": {"dev ops", "operations"}},
+		},
+		SnipsConfig: SnipsConfig{SlotName: "slack_names"},
+	}
+
+	t.Run("user aliases injected into slot", func(t *testing.T) {
+		users := []*slack.User{
+			{Id: "U1", Name: "jfoster", Profile: &slack.ProfileInfo{RealName: "Jodie Foster"}},
+		}
+
+		conf := Config{
+			SlackConfig: SlackConfig{
+				Aliases:           map[string][]string{"U1": {"JF"}},
+				AliasProfileField: "display_name",
+			},
+			SnipsConfig: SnipsConfig{SlotName: "slack_names"},
+		}
+
+		want := map[string][]string{"slack_names": {"Jodie Foster", "JF", "Jo"}}
+
+		got := EntityValues(conf, users, Profiles{"U1": {"display_name": "Jo"}}, nil)
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("channels injected into slot", func(t *testing.T) {
+		want := &Entity{
+			Ops: [][]interface{}{
+				{
+					"addFromVanilla",
+					map[string][]string{
+						"slack_names": []string{
+							"Jodie Foster",
+							"devops",
+							"dev ops",
+							"operations",
+							"team-backend",
+							"team backend",
+						},
+					},
+				},
+			},
+		}
+
+		got := BuildEntity(conf, users, channels)
+
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("channels injected into channel slot", func(t *testing.T) {
+		conf := conf
+		conf.SnipsConfig.ChannelSlotName = "slack_channels"
+
+		want := &Entity{
+			Ops: [][]interface{}{
+				{
+					"addFromVanilla",
+					map[string][]string{
+						"slack_names": []string{
+							"Jodie Foster",
+						},
+						"slack_channels": []string{
+							"devops",
+							"dev ops",
+							"operations",
+							"team-backend",
+							"team backend",
+						},
+					},
+				},
+			},
+		}
+
+		got := BuildEntity(conf, users, channels)
+
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("only channels", func(t *testing.T) {
+		got := BuildEntity(conf, nil, channels[:1])
+		if got == nil {
+			t.Fatal("expected entity but got nil")
+		}
+	})
+}
+
+func TestDiffEntity(t *testing.T) {
+	injected := map[string][]string{
+		"slack_names":    {"Jodie Foster", "Ted Levine"},
+		"slack_channels": {"devops"},
+	}
+
+	specs := []struct {
+		name     string
+		injected map[string][]string
+		next     map[string][]string
+		want     *Entity
+	}{
+		{
+			name:     "nothing injected",
+			injected: nil,
+			next:     injected,
+			want:     &Entity{Ops: [][]interface{}{{"addFromVanilla", injected}}},
+		},
+		{
+			name:     "nothing to inject",
+			injected: nil,
+			next:     map[string][]string{},
+			want:     nil,
+		},
+		{
+			name:     "unchanged in a different order",
+			injected: injected,
+			next: map[string][]string{
+				"slack_names":    {"Ted Levine", "Jodie Foster"},
+				"slack_channels": {"devops"},
+			},
+			want: nil,
+		},
+		{
+			name:     "values added",
+			injected: injected,
+			next: map[string][]string{
+				"slack_names":    {"Jodie Foster", "Ted Levine", "Scott Glenn", "Scott Glenn"},
+				"slack_channels": {"devops", "sre"},
+			},
+			want: &Entity{Ops: [][]interface{}{{"add", map[string][]string{
+				"slack_names":    {"Scott Glenn"},
+				"slack_channels": {"sre"},
+			}}}},
+		},
+		{
+			name:     "values removed",
+			injected: injected,
+			next: map[string][]string{
+				"slack_names":    {"Jodie Foster", "Scott Glenn"},
+				"slack_channels": {"devops"},
+			},
+			want: &Entity{Ops: [][]interface{}{{"addFromVanilla", map[string][]string{
+				"slack_names":    {"Jodie Foster", "Scott Glenn"},
+				"slack_channels": {"devops"},
+			}}}},
+		},
+		{
+			name:     "slot emptied",
+			injected: injected,
+			next: map[string][]string{
+				"slack_names": {"Jodie Foster", "Ted Levine"},
+			},
+			want: &Entity{Ops: [][]interface{}{{"addFromVanilla", map[string][]string{
+				"slack_names":    {"Jodie Foster", "Ted Levine"},
+				"slack_channels": {},
+			}}}},
+		},
+	}
+
+	for _, s := range specs {
+		t.Run(s.name, func(t *testing.T) {
+			got := DiffEntity(s.injected, s.next)
+			if !cmp.Equal(s.want, got) {
+				t.Error(cmp.Diff(s.want, got))
+			}
+		})
+	}
+}
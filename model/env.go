@@ -0,0 +1,135 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+)
+
+// EnvPrefix prefixes the environment variables overriding config fields,
+// named after the json path i.e SSP_SLACK_CONFIG_TOKEN for slack_config.token
+const EnvPrefix = "SSP"
+
+// envFileSuffix names the variant of a variable
+// holding the path of a file to read the value from
+const envFileSuffix = "_FILE"
+
+var durationType = reflect.TypeOf(Duration{})
+
+// ApplyEnv overrides the config fields with the environment variables
+// found by lookup, normally os.LookupEnv, returning a *ValidationError
+// for any which couldn't be read or parsed
+//
+// Strings and durations are used as is, string lists are comma
+// separated unless given as a json array and every other field is
+// decoded as json. Fields tagged env:"json", such as messages which
+// often hold commas, are only decoded as json.
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	errs := &ValidationError{}
+	applyEnv(errs, reflect.ValueOf(c).Elem(), "", EnvPrefix, lookup)
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func applyEnv(errs *ValidationError, v reflect.Value, path, name string, lookup func(string) (string, bool)) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+		fpath := tag
+		if path != "" {
+			fpath = path + "." + tag
+		}
+		fname := name + "_" + strings.ToUpper(tag)
+
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			applyEnv(errs, fv, fpath, fname, lookup)
+			continue
+		}
+
+		s, ok, err := lookupEnv(fname, lookup)
+		if err != nil {
+			errs.add(fpath, "%s", err)
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		if t.Field(i).Tag.Get("env") == "json" {
+			err = json.Unmarshal([]byte(s), fv.Addr().Interface())
+		} else {
+			err = setEnvValue(fv, s)
+		}
+
+		if err != nil {
+			errs.add(fpath, "invalid %s: %s", fname, err)
+		}
+	}
+}
+
+// lookupEnv returns the variable's value or the
+// contents of the file named by its _FILE variant
+func lookupEnv(name string, lookup func(string) (string, bool)) (string, bool, error) {
+	s, ok := lookup(name)
+	path, fok := lookup(name + envFileSuffix)
+
+	switch {
+	case ok && fok:
+		return "", false, fmt.Errorf("only one of %s and %s%s may be set", name, name, envFileSuffix)
+	case fok:
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%s%s: %s", name, envFileSuffix, err)
+		}
+
+		// Secret files usually end in a newline
+		return strings.TrimRight(string(b), "\r\n"), true, nil
+	default:
+		return s, ok, nil
+	}
+}
+
+func setEnvValue(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == durationType:
+		b, _ := json.Marshal(s)
+		return v.Addr().Interface().(*Duration).UnmarshalJSON(b)
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		if strings.HasPrefix(strings.TrimSpace(s), "[") {
+			return json.Unmarshal([]byte(s), v.Addr().Interface())
+		}
+
+		l := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, e := range strings.Split(s, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				l = reflect.Append(l, reflect.ValueOf(e))
+			}
+		}
+		v.Set(l)
+	case v.Kind() == reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := setEnvValue(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+	default:
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfigApplyEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(secret, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	lookupFrom := func(env map[string]string) func(string) (string, bool) {
+		return func(name string) (string, bool) {
+			s, ok := env[name]
+			return s, ok
+		}
+	}
+
+	t.Run("overrides fields", func(t *testing.T) {
+		conf := Config{
+			SlackConfig: SlackConfig{Token: "from-file", Username: "bot"},
+			MQTTConfig:  MQTTConfig{Hosts: []string{"localhost:1883"}},
+		}
+
+		err := conf.ApplyEnv(lookupFrom(map[string]string{
+			"SSP_NOTIFIER":                             "slack",
+			"SSP_SLACK_CONFIG_TOKEN":                   "xoxb-env",
+			"SSP_SLACK_CONFIG_MESSAGES":                `["Morning {{.FirstName}}, standup is on", "skates on"]`,
+			"SSP_SLACK_CONFIG_BLACKLIST":               "U1, ,C2",
+			"SSP_SLACK_CONFIG_CONVERSATION_TYPES":      `["public_channel", "mpim"]`,
+			"SSP_SLACK_CONFIG_CACHE_MAX_AGE":           "1h",
+			"SSP_SLACK_CONFIG_USER_ALIASES":            `{"U1": ["Jonny"]}`,
+			"SSP_SNIPS_CONFIG_INTENTS":                 `[{"name": "user:absent", "action": "list_absent"}]`,
+			"SSP_SNIPS_CONFIG_MIN_SLOT_CONFIDENCE":     "0.5",
+			"SSP_MQTT_CONFIG_HOST":                     "ssl://broker:8883",
+			"SSP_MQTT_CONFIG_PASSWORD_FILE":            secret,
+			"SSP_MQTT_CONFIG_CLEAN_SESSION":            "false",
+			"SSP_MQTT_CONFIG_PUBLISH_QOS":              "2",
+			"SSP_MQTT_CONFIG_TLS_INSECURE_SKIP_VERIFY": "true",
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clean, qos := false, 2
+		want := Config{
+			Notifier: NotifierSlack,
+			SlackConfig: SlackConfig{
+				Token:             "xoxb-env",
+				Username:          "bot",
+				Messages:          []string{"Morning {{.FirstName}}, standup is on", "skates on"},
+				Blacklist:         []string{"U1", "C2"},
+				ConversationTypes: []string{"public_channel", "mpim"},
+				CacheMaxAge:       Duration{time.Hour},
+				Aliases:           map[string][]string{"U1": {"Jonny"}},
+			},
+			SnipsConfig: SnipsConfig{
+				Intents:           []IntentConfig{{Name: "user:absent", Action: ActionListAbsent}},
+				MinSlotConfidence: 0.5,
+			},
+			MQTTConfig: MQTTConfig{
+				Hosts:        []string{"ssl://broker:8883"},
+				Password:     "s3cret",
+				CleanSession: &clean,
+				PublishQoS:   &qos,
+				TLS:          TLSConfig{InsecureSkipVerify: true},
+			},
+		}
+
+		if !cmp.Equal(want, conf) {
+			t.Error(cmp.Diff(want, conf))
+		}
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		conf := Config{MQTTConfig: MQTTConfig{Password: "unchanged"}}
+
+		err := conf.ApplyEnv(lookupFrom(map[string]string{
+			"SSP_SLACK_CONFIG_TOKEN":           "xoxb",
+			"SSP_SLACK_CONFIG_TOKEN_FILE":      secret,
+			"SSP_SNIPS_CONFIG_SESSION_TIMEOUT": "soon",
+			"SSP_MQTT_CONFIG_CONNECT_ATTEMPTS": "many",
+			"SSP_MQTT_CONFIG_PASSWORD_FILE":    filepath.Join(dir, "missing"),
+			"SSP_SLACK_CONFIG_MESSAGES":        "Morning {{.FirstName}}, standup is on",
+		}))
+
+		assertValidationError(t, err, []FieldError{
+			{Field: "slack_config.token", Message: "only one of SSP_SLACK_CONFIG_TOKEN and SSP_SLACK_CONFIG_TOKEN_FILE may be set"},
+			{Field: "slack_config.messages", Message: "invalid SSP_SLACK_CONFIG_MESSAGES: invalid character 'M' looking for beginning of value"},
+			{Field: "snips_config.session_timeout", Message: `invalid SSP_SNIPS_CONFIG_SESSION_TIMEOUT: time: invalid duration "soon"`},
+			{Field: "mqtt_config.password", Message: "SSP_MQTT_CONFIG_PASSWORD_FILE: open " + filepath.Join(dir, "missing") + ": no such file or directory"},
+			{Field: "mqtt_config.connect_attempts", Message: "invalid SSP_MQTT_CONFIG_CONNECT_ATTEMPTS: invalid character 'm' looking for beginning of value"},
+		})
+
+		if conf.MQTTConfig.Password != "unchanged" {
+			t.Errorf("expected password to be unchanged but got %q", conf.MQTTConfig.Password)
+		}
+	})
+
+	t.Run("nothing set", func(t *testing.T) {
+		conf := newDefaultConfig()
+		if err := conf.ApplyEnv(lookupFrom(nil)); err != nil {
+			t.Fatal(err)
+		}
+
+		if want := newDefaultConfig(); !cmp.Equal(want, conf) {
+			t.Error(cmp.Diff(want, conf))
+		}
+	})
+}
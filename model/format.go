@@ -0,0 +1,204 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config file formats, every format is converted
+// through json so the json struct tags are honored
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// formatFromPath returns the config format for the file
+// extension, anything unrecognised is treated as json
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// decodeConfig decodes b in the format into conf
+func decodeConfig(format string, b []byte, conf *Config) error {
+	var v interface{}
+
+	switch format {
+	case FormatJSON:
+		return json.NewDecoder(bytes.NewReader(b)).Decode(conf)
+	case FormatYAML:
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return err
+		}
+	case FormatTOML:
+		var m map[string]interface{}
+		if _, err := toml.Decode(string(b), &m); err != nil {
+			return err
+		}
+		v = m
+	default:
+		return fmt.Errorf("unsupported config format %q", format)
+	}
+
+	v, err := stringKeys(v)
+	if err != nil {
+		return err
+	}
+
+	jb, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(jb, conf)
+}
+
+// encodeConfig encodes conf in the format keeping
+// the field order of the json encoding where possible
+func encodeConfig(format string, conf Config) ([]byte, error) {
+	jb, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil || format == FormatJSON {
+		return jb, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(jb))
+	dec.UseNumber()
+
+	v, err := orderedValue(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatYAML:
+		return yaml.Marshal(v)
+	case FormatTOML:
+		var buf bytes.Buffer
+		err := toml.NewEncoder(&buf).Encode(unorderedValue(v))
+		return buf.Bytes(), err
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", format)
+	}
+}
+
+// stringKeys converts the map[interface{}]interface{}
+// decoded by yaml into maps json is able to encode
+func stringKeys(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			s, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("config key %v must be a string", k)
+			}
+
+			var err error
+			if m[s], err = stringKeys(e); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case map[string]interface{}:
+		for k, e := range t {
+			var err error
+			if t[k], err = stringKeys(e); err != nil {
+				return nil, err
+			}
+		}
+		return t, nil
+	case []interface{}:
+		for i, e := range t {
+			var err error
+			if t[i], err = stringKeys(e); err != nil {
+				return nil, err
+			}
+		}
+		return t, nil
+	default:
+		return v, nil
+	}
+}
+
+// orderedValue reads the next json value from dec decoding
+// objects as a yaml.MapSlice to keep the keys in order
+func orderedValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			l := []interface{}{}
+			for dec.More() {
+				e, err := orderedValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				l = append(l, e)
+			}
+			_, err := dec.Token()
+			return l, err
+		}
+
+		m := yaml.MapSlice{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			e, err := orderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, yaml.MapItem{Key: k, Value: e})
+		}
+		_, err := dec.Token()
+		return m, err
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	default:
+		return t, nil
+	}
+}
+
+// unorderedValue converts the yaml.MapSlice values from
+// orderedValue into maps, dropping nulls toml can't encode
+func unorderedValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case yaml.MapSlice:
+		m := make(map[string]interface{}, len(t))
+		for _, e := range t {
+			if e.Value != nil {
+				m[e.Key.(string)] = unorderedValue(e.Value)
+			}
+		}
+		return m
+	case []interface{}:
+		for i, e := range t {
+			t[i] = unorderedValue(e)
+		}
+		return t
+	default:
+		return v
+	}
+}
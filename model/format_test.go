@@ -0,0 +1,153 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormatFromPath(t *testing.T) {
+	specs := map[string]string{
+		"config.json":      FormatJSON,
+		"config.yaml":      FormatYAML,
+		"config.YML":       FormatYAML,
+		"config.toml":      FormatTOML,
+		"/etc/ssp/config":  FormatJSON,
+		"config.yaml.json": FormatJSON,
+	}
+
+	for path, want := range specs {
+		if got := formatFromPath(path); got != want {
+			t.Errorf("expected %s for %q but got %s", want, path, got)
+		}
+	}
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	qos := 1
+	want := Config{
+		SnipsConfig: SnipsConfig{
+			SlackIntent:      "intent",
+			InjectionTimeout: Duration{time.Minute},
+			Intents: []IntentConfig{
+				{Name: "user:standup", Action: ActionStandupMessage, Message: "standup"},
+			},
+		},
+		MQTTConfig: MQTTConfig{
+			Hosts:        []string{"localhost:1833"},
+			Username:     "user",
+			KeepAlive:    Duration{30 * time.Second},
+			SubscribeQoS: &qos,
+		},
+	}
+
+	specs := map[string]string{
+		"config.yaml": `
+snips_config:
+  slack_intent: intent
+  injection_timeout: 1m
+  intents:
+    - name: user:standup
+      action: standup_message
+      message: standup
+mqtt_config:
+  host: [localhost:1833]
+  username: user
+  keep_alive: 30s
+  subscribe_qos: 1
+`,
+		"config.toml": `
+[snips_config]
+slack_intent = "intent"
+injection_timeout = "1m"
+
+[[snips_config.intents]]
+name = "user:standup"
+action = "standup_message"
+message = "standup"
+
+[mqtt_config]
+host = ["localhost:1833"]
+username = "user"
+keep_alive = "30s"
+subscribe_qos = 1
+`,
+	}
+
+	for name, data := range specs {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !cmp.Equal(want, got) {
+				t.Error(cmp.Diff(want, got))
+			}
+		})
+	}
+
+	t.Run("invalid yaml", func(t *testing.T) {
+		path := filepath.Join(dir, "invalid.yml")
+		if err := ioutil.WriteFile(path, []byte("mqtt_config: [\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := LoadConfig(path); err == nil {
+			t.Fatal("expected an error for invalid yaml")
+		}
+	})
+}
+
+func TestGenerateConfigFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, format := range []string{FormatJSON, FormatYAML, FormatTOML} {
+		t.Run(format, func(t *testing.T) {
+			s, err := GenerateConfig(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(dir, "config."+format)
+			if err := ioutil.WriteFile(path, []byte(s), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := newDefaultConfig(); !cmp.Equal(want, got) {
+				t.Error(cmp.Diff(want, got))
+			}
+		})
+	}
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := GenerateConfig("ini")
+		if err == nil || err.Error() != `unsupported config format "ini"` {
+			t.Errorf("expected unsupported format error but got %v", err)
+		}
+	})
+}
@@ -4,6 +4,7 @@ package model
 // from a mqtt.Message defined by snips
 type Payload struct {
 	SessionID string                 `json:"sessionId"`
+	SiteID    string                 `json:"siteId"`
 	Values    map[string]interface{} `json:"customData"`
 	Intent    Intent                 `json:"intent"`
 	Slots     []Slot                 `json:"slots"`
@@ -39,3 +40,25 @@ type EndSession struct {
 	SessionID string `json:"sessionId"`
 	Text      string `json:"text"`
 }
+
+// InjectionComplete is published by snips
+// once the injection request has completed
+type InjectionComplete struct {
+	RequestID string `json:"requestId"`
+}
+
+// InjectionStatus is published by snips in
+// response to an injection status request
+type InjectionStatus struct {
+	LastInjectionDate string `json:"lastInjectionDate"`
+}
+
+// ContinueSession holds outbound message when asking
+// the user a question and keeping the session open
+type ContinueSession struct {
+	SessionID string `json:"sessionId"`
+	Text      string `json:"text"`
+	// IntentFilter restricts the intents
+	// expected in the user's answer
+	IntentFilter []string `json:"intentFilter,omitempty"`
+}
@@ -0,0 +1,182 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"text/template"
+	"time"
+)
+
+// MessageData is the context messages are rendered with as
+// text/templates, i.e "Morning {{.FirstName}}, it's {{.Weekday}}"
+type MessageData struct {
+	// Name is the resolved name of the user or channel
+	Name      string
+	FirstName string
+	RealName  string
+	// Mention is the notifier's syntax to
+	// mention the target, i.e <@U012AB3CD>
+	Mention string
+	Channel bool
+
+	// Site is the snips site the ping was requested from
+	Site string
+	// Time is the local time the message is sent
+	Time    time.Time
+	Weekday string
+}
+
+// NewMessageData returns the message data with
+// the time and weekday set from the local time t
+func NewMessageData(t time.Time) MessageData {
+	t = t.Local()
+	return MessageData{Time: t, Weekday: t.Weekday().String()}
+}
+
+// exampleMessageData is used to check messages render
+var exampleMessageData = MessageData{
+	Name:      "Alice Smith",
+	FirstName: "Alice",
+	RealName:  "Alice Smith",
+	Mention:   "@alice",
+	Site:      "default",
+	Time:      time.Date(2019, time.January, 7, 9, 30, 0, 0, time.UTC),
+	Weekday:   "Monday",
+}
+
+// RenderMessage executes the message template with d
+func RenderMessage(text string, d MessageData) (string, error) {
+	tmpl, err := template.New("message").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, d); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// validateMessages parses and executes each message so unknown
+// fields and syntax errors are found before any are sent
+func validateMessages(errs *ValidationError, field string, msgs []string) {
+	for i, m := range msgs {
+		validateMessage(errs, fmt.Sprintf("%s[%d]", field, i), m)
+	}
+}
+
+func validateMessage(errs *ValidationError, field, msg string) {
+	tmpl, err := template.New("message").Parse(msg)
+	if err == nil {
+		err = tmpl.Execute(ioutil.Discard, exampleMessageData)
+	}
+
+	if err != nil {
+		errs.add(field, "invalid message template: %s", err)
+	}
+}
+
+// RichMessage is a message posted with slack attachments or
+// Block Kit blocks given as json, Text is the plain text fallback
+// shown in notifications and by clients unable to show them
+type RichMessage struct {
+	Text        string          `json:"text"`
+	Attachments json.RawMessage `json:"attachments,omitempty"`
+	Blocks      json.RawMessage `json:"blocks,omitempty"`
+}
+
+// RenderRichMessage executes the text and every string in
+// the attachments and blocks as message templates with d
+func RenderRichMessage(m RichMessage, d MessageData) (RichMessage, error) {
+	var err error
+	if m.Text, err = RenderMessage(m.Text, d); err != nil {
+		return m, err
+	}
+
+	if m.Attachments, err = renderJSON(m.Attachments, d); err != nil {
+		return m, err
+	}
+
+	m.Blocks, err = renderJSON(m.Blocks, d)
+	return m, err
+}
+
+// renderJSON renders every string value in the json array b
+func renderJSON(b json.RawMessage, d MessageData) (json.RawMessage, error) {
+	if len(b) == 0 {
+		return b, nil
+	}
+
+	var v []interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	if err := renderValue(v, d); err != nil {
+		return nil, err
+	}
+
+	// Keep mentions such as <@U012AB3CD> readable
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
+
+// renderValue renders the strings of the decoded json in place
+func renderValue(v interface{}, d MessageData) error {
+	var err error
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if s, ok := e.(string); ok {
+				t[k], err = RenderMessage(s, d)
+			} else {
+				err = renderValue(e, d)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, e := range t {
+			if s, ok := e.(string); ok {
+				t[i], err = RenderMessage(s, d)
+			} else {
+				err = renderValue(e, d)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m RichMessage) validate(errs *ValidationError, field string) {
+	if m.Text == "" {
+		errs.add(field+".text", "rich message plain text fallback required")
+	}
+
+	validateMessage(errs, field+".text", m.Text)
+
+	if _, err := renderJSON(m.Attachments, exampleMessageData); err != nil {
+		errs.add(field+".attachments", "invalid rich message attachments: %s", err)
+	}
+
+	if _, err := renderJSON(m.Blocks, exampleMessageData); err != nil {
+		errs.add(field+".blocks", "invalid rich message blocks: %s", err)
+	}
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRenderMessage(t *testing.T) {
+	d := NewMessageData(time.Date(2019, time.January, 11, 9, 30, 0, 0, time.Local))
+	d.FirstName = "Jodie"
+	d.Mention = "<@U1>"
+	d.Site = "kitchen"
+
+	specs := []struct {
+		in, want string
+	}{
+		{"Put your skates on… it’s standup!", "Put your skates on… it’s standup!"},
+		{"{{.Mention}} standup is starting", "<@U1> standup is starting"},
+		{"Morning {{.FirstName}}, happy {{.Weekday}}!", "Morning Jodie, happy Friday!"},
+		{`Asked from the {{.Site}} at {{.Time.Format "15:04"}}`, "Asked from the kitchen at 09:30"},
+	}
+
+	for _, s := range specs {
+		got, err := RenderMessage(s.in, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != s.want {
+			t.Errorf("expected %q but got %q", s.want, got)
+		}
+	}
+
+	if _, err := RenderMessage("{{.Nickname}}", d); err == nil {
+		t.Error("expected an error rendering an unknown field")
+	}
+}
+
+func TestValidateMessages(t *testing.T) {
+	errs := &ValidationError{}
+	validateMessages(errs, "slack_config.messages", []string{
+		"standup {{.FirstName}}",
+		"standup {{.FirstName",
+		"standup {{.Nickname}}",
+	})
+
+	assertValidationError(t, errs, []FieldError{
+		{Field: "slack_config.messages[1]", Message: `invalid message template: template: message:1: unclosed action`},
+		{Field: "slack_config.messages[2]", Message: `invalid message template: template: message:1:10: executing "message" at <.Nickname>: can't evaluate field Nickname in type model.MessageData`},
+	})
+}
+
+func TestRenderRichMessage(t *testing.T) {
+	d := NewMessageData(time.Date(2019, time.January, 11, 9, 30, 0, 0, time.Local))
+	d.FirstName = "Jodie"
+	d.Mention = "<@U1>"
+
+	m := RichMessage{
+		Text:        "{{.FirstName}}, standup is starting",
+		Attachments: json.RawMessage(`[{"fallback": "Standup", "text": "Happy {{.Weekday}}"}]`),
+		Blocks: json.RawMessage(`[
+			{"type": "section", "text": {"type": "mrkdwn", "text": "{{.Mention}} standup is starting"}},
+			{"type": "actions", "elements": [
+				{"type": "button", "text": {"type": "plain_text", "text": "Join standup"}, "url": "https://meet.example.com/standup"}
+			]}
+		]`),
+	}
+
+	got, err := RenderRichMessage(m, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := RichMessage{
+		Text:        "Jodie, standup is starting",
+		Attachments: json.RawMessage(`[{"fallback":"Standup","text":"Happy Friday"}]`),
+		Blocks:      json.RawMessage(`[{"text":{"text":"<@U1> standup is starting","type":"mrkdwn"},"type":"section"},{"elements":[{"text":{"text":"Join standup","type":"plain_text"},"type":"button","url":"https://meet.example.com/standup"}],"type":"actions"}]`),
+	}
+
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestRichMessageValidate(t *testing.T) {
+	errs := &ValidationError{}
+
+	RichMessage{Blocks: json.RawMessage(`[{"text": "{{.Nickname}}"}]`)}.validate(errs, "slack_config.rich_messages[0]")
+	RichMessage{Text: "standup", Attachments: json.RawMessage(`{"text": "standup"}`)}.validate(errs, "slack_config.rich_messages[1]")
+
+	assertValidationError(t, errs, []FieldError{
+		{Field: "slack_config.rich_messages[0].text", Message: "rich message plain text fallback required"},
+		{Field: "slack_config.rich_messages[0].blocks", Message: `invalid rich message blocks: template: message:1:2: executing "message" at <.Nickname>: can't evaluate field Nickname in type model.MessageData`},
+		{Field: "slack_config.rich_messages[1].attachments", Message: "invalid rich message attachments: json: cannot unmarshal object into Go value of type []interface {}"},
+	})
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSConfig holds the optional TLS options used
+// when connecting to an ssl:// or wss:// broker
+type TLSConfig struct {
+	// CAFile is a PEM bundle of the certificate authorities
+	// trusted to verify the broker, the system pool when empty
+	CAFile string `json:"ca_file"`
+
+	// CertFile and KeyFile are the PEM encoded client
+	// certificate and key presented for mutual TLS
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+
+	// ServerName overrides the host name the broker's
+	// certificate is verified against
+	ServerName string `json:"server_name"`
+
+	// InsecureSkipVerify disables verifying the broker's
+	// certificate, only use it for testing
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+}
+
+// Load builds the tls config loading the CA bundle and client
+// certificate, returns nil when no TLS options are set
+func (t TLSConfig) Load() (*tls.Config, error) {
+	if t == (TLSConfig{}) {
+		return nil, nil
+	}
+
+	tc := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pool, err := loadCertPool(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tc.RootCAs = pool
+	}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return nil, errors.New("cert and key files must be set together")
+	}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate %s", err)
+		}
+
+		tc.Certificates = []tls.Certificate{cert}
+	}
+
+	return tc, nil
+}
+
+func (t TLSConfig) validate(errs *ValidationError) {
+	if t.CAFile != "" {
+		if _, err := loadCertPool(t.CAFile); err != nil {
+			errs.add("mqtt_config.tls.ca_file", "mqtt tls %s", err)
+		}
+	}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		errs.add("mqtt_config.tls.key_file", "mqtt tls cert and key files must be set together")
+		return
+	}
+
+	if t.CertFile != "" {
+		if _, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+			errs.add("mqtt_config.tls.cert_file", "mqtt tls client certificate %s", err)
+		}
+	}
+}
+
+// loadCertPool reads the PEM certificates in path into a pool
+func loadCertPool(path string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ca file %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("ca file %s has no PEM certificates", path)
+	}
+
+	return pool, nil
+}
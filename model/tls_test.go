@@ -0,0 +1,142 @@
+package model
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self signed PEM certificate
+// and key into dir returning their paths
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "broker.local"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kb, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestTLSConfigLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssp-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeTestCert(t, dir)
+
+	t.Run("no options", func(t *testing.T) {
+		tc, err := TLSConfig{}.Load()
+		if tc != nil || err != nil {
+			t.Fatalf("expected no tls config but got %v %v", tc, err)
+		}
+	})
+
+	t.Run("mutual tls", func(t *testing.T) {
+		tc, err := TLSConfig{
+			CAFile:     certFile,
+			CertFile:   certFile,
+			KeyFile:    keyFile,
+			ServerName: "broker.local",
+		}.Load()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tc.RootCAs == nil {
+			t.Error("expected ca pool to be loaded")
+		}
+
+		if len(tc.Certificates) != 1 {
+			t.Errorf("expected one client certificate but got %d", len(tc.Certificates))
+		}
+
+		if tc.ServerName != "broker.local" {
+			t.Errorf("expected server name broker.local but got %q", tc.ServerName)
+		}
+	})
+
+	t.Run("insecure skip verify", func(t *testing.T) {
+		tc, err := TLSConfig{InsecureSkipVerify: true}.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !tc.InsecureSkipVerify {
+			t.Error("expected insecure skip verify to be set")
+		}
+	})
+
+	specs := []struct {
+		name      string
+		config    TLSConfig
+		wantField string
+		wantErr   string
+	}{
+		{"missing ca file", TLSConfig{CAFile: filepath.Join(dir, "missing.pem")}, "mqtt_config.tls.ca_file", "ca file open"},
+		{"unparsable ca file", TLSConfig{CAFile: keyFile}, "mqtt_config.tls.ca_file", "ca file " + keyFile + " has no PEM certificates"},
+		{"cert without key", TLSConfig{CertFile: certFile}, "mqtt_config.tls.key_file", "cert and key files must be set together"},
+		{"mismatched key", TLSConfig{CertFile: keyFile, KeyFile: certFile}, "mqtt_config.tls.cert_file", "client certificate"},
+	}
+
+	for _, s := range specs {
+		t.Run(s.name, func(t *testing.T) {
+			_, err := s.config.Load()
+			if err == nil || !strings.HasPrefix(err.Error(), s.wantErr) {
+				t.Fatalf("expected error %q but got %v", s.wantErr, err)
+			}
+
+			errs := &ValidationError{}
+			s.config.validate(errs)
+
+			if len(errs.Errors) != 1 || errs.Errors[0].Field != s.wantField ||
+				!strings.HasPrefix(errs.Errors[0].Message, "mqtt tls "+s.wantErr) {
+				t.Errorf("expected %s error %q but got %+v", s.wantField, s.wantErr, errs.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError is a problem with a single config
+// field given by its path, i.e slack_config.token
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError holds every problem found validating the config
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+// Error renders the problems one per line
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Errors)+1)
+	lines = append(lines, "Following error(s) with config:")
+
+	for _, fe := range e.Errors {
+		lines = append(lines, fmt.Sprintf(" - %s: %s", fe.Field, fe.Message))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// add records a problem with the field
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidationError(t *testing.T) {
+	errs := &ValidationError{}
+	errs.add("slack_config.token", "slack token required")
+	errs.add("snips_config.intents[1].action", "snips intent %d unknown action %q", 1, "dance")
+
+	t.Run("renders one per line", func(t *testing.T) {
+		want := "Following error(s) with config:\n" +
+			" - slack_config.token: slack token required\n" +
+			` - snips_config.intents[1].action: snips intent 1 unknown action "dance"`
+
+		if got := errs.Error(); got != want {
+			t.Fatal(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("encodes as json", func(t *testing.T) {
+		b, err := json.Marshal(errs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := `{"errors":[` +
+			`{"field":"slack_config.token","message":"slack token required"},` +
+			`{"field":"snips_config.intents[1].action","message":"snips intent 1 unknown action \"dance\""}]}`
+
+		if got := string(b); got != want {
+			t.Fatal(cmp.Diff(want, got))
+		}
+	})
+}
@@ -11,39 +11,59 @@ import (
 	"github.com/jnormington/snips-slack-pinger/model"
 )
 
-type slackHandlerFn func(model.SlackConfig, string) error
-
 type mqttClient struct {
 	config model.Config
 	client mqtt.Client
 	errCh  chan error
 	connCh chan bool
+	// refreshCh requests an immediate refresh of the slack cache
+	refreshCh chan struct{}
 
-	slackHandler slackHandlerFn
+	notifier   Notifier
+	sessions   *sessionStore
+	injections *injectionTracker
+	state      *connStateMachine
 }
 
 var (
-	ErrConnectFail      = errors.New("failed to connect to mqtt broker")
-	errInvalidSlotCount = errors.New("missing or too many slots from payload")
-	errPublishFailed    = errors.New("failed to publish end session")
+	ErrConnectFail   = errors.New("failed to connect to mqtt broker")
+	errMissingSlots  = errors.New("missing slots from payload")
+	errUnknownIntent = errors.New("I don't know how to handle that intent")
+	errPublishFailed = errors.New("failed to publish end session")
 
 	mqttClientFn = func(o *mqtt.ClientOptions) mqtt.Client {
 		return mqtt.NewClient(o)
 	}
+)
+
+const (
+	defaultConnectBackoff    = time.Second
+	defaultMaxConnectBackoff = 2 * time.Minute
+	defaultConnectTimeout    = 5 * time.Second
+	defaultKeepAlive         = 30 * time.Second
 
-	attempts = 5
+	statusOnline  = "online"
+	statusOffline = "offline"
 )
 
 // NewMQTTClient builds a new mqtt client based
 // the on the loaded configuration
-func NewMQTTClient(c model.Config, sh slackHandlerFn) mqttClient {
+func NewMQTTClient(c model.Config, n Notifier) (mqttClient, error) {
 	mqttClt := mqttClient{
-		config:       c,
-		errCh:        make(chan error),
-		connCh:       make(chan bool),
-		slackHandler: sh,
+		config:     c,
+		errCh:      make(chan error, 1),
+		connCh:     make(chan bool, 1),
+		refreshCh:  make(chan struct{}, 1),
+		notifier:   n,
+		sessions:   newSessionStore(c.SnipsConfig.SessionTimeout.Duration),
+		injections: newInjectionTracker(),
+		state:      newConnStateMachine(),
 	}
 
+	mqttClt.state.Observe(func(from, to ConnState) {
+		log.Printf("mqtt connection %s, was %s\n", to, from)
+	})
+
 	opts := mqtt.NewClientOptions()
 
 	for _, h := range c.MQTTConfig.Hosts {
@@ -54,54 +74,203 @@ func NewMQTTClient(c model.Config, sh slackHandlerFn) mqttClient {
 		return c.MQTTConfig.Username, c.MQTTConfig.Password
 	})
 
-	opts.SetConnectTimeout(5 * time.Second)
+	// The config has been validated so this only fails when the
+	// files changed since then, connecting without them would
+	// drop the pinned CA and client certificate
+	tc, err := c.MQTTConfig.TLS.Load()
+	if err != nil {
+		return mqttClient{}, fmt.Errorf("mqtt tls error: %s", err)
+	}
+
+	if tc != nil {
+		opts.SetTLSConfig(tc)
+	}
+
+	// Paho reconnects a lost connection by itself and the connected
+	// handler subscribes again as a clean session forgets them
+	opts.SetAutoReconnect(true)
+	opts.SetMaxReconnectInterval(mqttClt.maxConnectBackoff())
+	opts.SetConnectionLostHandler(mqttClt.ConnectionLostHandler)
+
+	mc := c.MQTTConfig
+	opts.SetClientID(mc.ClientID)
+	opts.SetCleanSession(mc.IsCleanSession())
+	opts.SetKeepAlive(durationOr(mc.KeepAlive.Duration, defaultKeepAlive))
+	opts.SetConnectTimeout(durationOr(mc.ConnectTimeout.Duration, defaultConnectTimeout))
+
+	// The broker publishes offline for us when
+	// the connection drops without disconnecting
+	if mc.StatusTopic != "" {
+		opts.SetWill(mc.StatusTopic, statusOffline, mc.PubQoS(), true)
+	}
+
 	opts.SetOnConnectHandler(mqttClt.ConnectedHandler)
 	opts.SetDefaultPublishHandler(mqttClt.MessageHandler)
 
 	mqttClt.client = mqttClientFn(opts)
-	return mqttClt
+	return mqttClt, nil
 }
 
-// ConnectToMQTTBroker attempts to connect with the broker
-// and any connection failure are returned
+// ConnectToMQTTBroker attempts to connect with the broker backing
+// off between attempts, ErrConnectFail is sent once the configured
+// attempts are exhausted otherwise it retries until connected
 func (mc mqttClient) ConnectToMQTTBroker() {
-	tok := mc.client.Connect()
-	if tok.Error() != nil {
-		mc.errCh <- tok.Error()
-	}
+	max := mc.config.MQTTConfig.ConnectAttempts
+	b := newBackoff(mc.connectBackoff(), mc.maxConnectBackoff())
+
+	mc.state.transition(StateConnecting)
+
+	for try := 1; ; try++ {
+		err := mc.connect()
+		if err == nil {
+			mc.state.transition(StateConnected)
+			mc.connCh <- true
+			return
+		}
 
-	var try int
-	for range time.Tick(time.Second * 1) {
-		try++
-		if try > attempts {
+		if max > 0 && try >= max {
+			log.Printf("connect attempt %d of %d failed: %s\n", try, max, err)
+			mc.state.transition(StateFailed)
 			mc.errCh <- ErrConnectFail
 			mc.connCh <- false
+			return
 		}
 
-		if mc.client.IsConnected() {
-			mc.errCh <- nil
-			mc.connCh <- true
-			break
-		}
+		d := b.next()
+		log.Printf("connect attempt %d failed, retrying in %s: %s\n", try, d, err)
+		time.Sleep(d)
 	}
 }
 
+// connect makes a single attempt to connect with the broker
+func (mc mqttClient) connect() error {
+	tok := mc.client.Connect()
+	tok.Wait()
+
+	if err := tok.Error(); err != nil {
+		return err
+	}
+
+	if !mc.client.IsConnected() {
+		return ErrConnectFail
+	}
+
+	return nil
+}
+
+func (mc mqttClient) connectBackoff() time.Duration {
+	return durationOr(mc.config.MQTTConfig.ConnectBackoff.Duration, defaultConnectBackoff)
+}
+
+func (mc mqttClient) maxConnectBackoff() time.Duration {
+	return durationOr(mc.config.MQTTConfig.MaxConnectBackoff.Duration, defaultMaxConnectBackoff)
+}
+
+// durationOr returns d when set otherwise the default
+func durationOr(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+
+	return def
+}
+
+// ConnState returns the state of the connection to the broker
+func (mc mqttClient) ConnState() ConnState {
+	return mc.state.State()
+}
+
+// ConnectionLostHandler is called by mqtt.Client when an
+// established connection is lost before it reconnects
+func (mc mqttClient) ConnectionLostHandler(c mqtt.Client, err error) {
+	log.Println("lost connection to MQTT:", err)
+	mc.state.transition(StateReconnecting)
+}
+
 // ConnectedHandler is called by mqtt.Client when connected
 // this handler is responsbile for registering interest in specific
 // intents for that it needs to do actions for
 func (mc mqttClient) ConnectedHandler(c mqtt.Client) {
 	log.Println("connected to MQTT")
-	si := mc.config.SnipsConfig.SlackIntent
+	mc.state.transition(StateConnected)
 
-	log.Printf("registering for events on intent %q\n", si)
-	tok := c.Subscribe(fmt.Sprintf("hermes/intent/%s", si), 0, nil)
-	if tok.Error() != nil {
-		go func(err error) {
-			mc.errCh <- err
-		}(tok.Error())
+	qos := mc.config.MQTTConfig.SubQoS()
+
+	var intents []string
+	for _, r := range mc.config.SnipsConfig.Routes() {
+		intents = append(intents, r.Name)
+	}
+
+	intents = append(intents, mc.config.SnipsConfig.DialogueIntents()...)
+
+	for _, i := range intents {
+		log.Printf("registering for events on intent %q\n", i)
+		tok := c.Subscribe(fmt.Sprintf("hermes/intent/%s", i), qos, nil)
+		if tok.Error() != nil {
+			go func(err error) {
+				mc.errCh <- err
+			}(tok.Error())
+			return
+		}
+	}
+
+	injections := []struct {
+		topic   string
+		handler mqtt.MessageHandler
+	}{
+		{"hermes/injection/complete", mc.InjectionCompleteHandler},
+		{"hermes/injection/status", mc.InjectionStatusHandler},
+	}
+
+	for _, i := range injections {
+		log.Printf("registering for injection results on %q\n", i.topic)
+		tok := c.Subscribe(i.topic, qos, i.handler)
+		if tok.Error() != nil {
+			go func(err error) {
+				mc.errCh <- err
+			}(tok.Error())
+			return
+		}
+	}
+
+	if tok := c.Publish("hermes/injection/statusRequest", mc.config.MQTTConfig.PubQoS(), false, []byte("{}")); tok.Error() != nil {
+		log.Println("publish injection status request error:", tok.Error())
+	}
+
+	if rt := mc.config.MQTTConfig.RefreshTopic; rt != "" {
+		log.Printf("registering for refresh requests on %q\n", rt)
+		tok := c.Subscribe(rt, qos, mc.RefreshHandler)
+		if tok.Error() != nil {
+			go func(err error) {
+				mc.errCh <- err
+			}(tok.Error())
+			return
+		}
+	}
+
+	if err := mc.PublishStatus(c, statusOnline); err != nil {
+		log.Println("publish status error:", err)
+	}
+}
+
+// RefreshHandler requests a refresh of the slack cache
+// when a message is published on the refresh topic
+func (mc mqttClient) RefreshHandler(c mqtt.Client, msg mqtt.Message) {
+	log.Println("refresh requested on", msg.Topic())
+	mc.RequestRefresh()
+}
+
+// RequestRefresh asks for an immediate refresh of the slack cache,
+// requests made while one is already pending are coalesced
+func (mc mqttClient) RequestRefresh() {
+	select {
+	case mc.refreshCh <- struct{}{}:
+	default:
 	}
 }
 
+// MessageHandler dispatches intent messages to the
+// action routed to the payload's intent name
 func (mc mqttClient) MessageHandler(c mqtt.Client, msg mqtt.Message) {
 	log.Println("recieved message")
 	var p model.Payload
@@ -113,24 +282,52 @@ func (mc mqttClient) MessageHandler(c mqtt.Client, msg mqtt.Message) {
 		return
 	}
 
-	// We won't get here if slot is required
-	// but if not set to required we will
-	if len(p.Slots) != 1 {
-		log.Println(errInvalidSlotCount)
-		if err := PublishEndSession(c, p.SessionID, errInvalidSlotCount.Error()); err != nil {
+	// Answers to questions asked are handled before
+	// routing as they may be for any of the intents
+	if ps, ok, err := mc.sessions.take(p.SessionID); ok {
+		text := ""
+		if err == nil {
+			text, err = mc.answerSession(p, ps)
+		}
+
+		mc.endSession(c, p.SessionID, text, err)
+		return
+	}
+
+	route, ok := mc.config.SnipsConfig.Route(p.Intent.Name)
+	if !ok && containsString(mc.config.SnipsConfig.DialogueIntents(), p.Intent.Name) {
+		// Yes/no answers are often shared with other apps
+		// so dialogues which aren't ours are left alone
+		log.Printf("ignoring %q answering a session which isn't ours\n", p.Intent.Name)
+		return
+	}
+
+	if !ok {
+		log.Printf("no route for intent %q\n", p.Intent.Name)
+		if err := PublishEndSession(c, mc.config.MQTTConfig.PubQoS(), p.SessionID, errUnknownIntent.Error()); err != nil {
 			log.Println(err.Error(), err)
 		}
 		return
 	}
 
-	value := p.Slots[0].Value.Value
-	err = mc.slackHandler(mc.config.SlackConfig, value)
-	if err != nil {
-		PublishEndSession(c, p.SessionID, err.Error())
+	text, err := actions[route.Action](mc, p, route)
+	if err != nil && mc.continueSession(c, p, route, err) {
+		log.Println("waiting on answer:", err)
 		return
 	}
 
-	if err := PublishEndSession(c, p.SessionID, "I've slacked "+value); err != nil {
+	mc.endSession(c, p.SessionID, text, err)
+}
+
+// endSession ends the session speaking the
+// error when set otherwise the text
+func (mc mqttClient) endSession(c mqtt.Client, sessionID, text string, err error) {
+	if err != nil {
+		log.Println(err)
+		text = err.Error()
+	}
+
+	if err := PublishEndSession(c, mc.config.MQTTConfig.PubQoS(), sessionID, text); err != nil {
 		log.Println(err.Error(), err)
 	}
 
@@ -140,12 +337,41 @@ func (mc mqttClient) MessageHandler(c mqtt.Client, msg mqtt.Message) {
 func (mc mqttClient) PublishEntity(e *model.Entity) error {
 	b, _ := json.Marshal(e)
 
-	tok := mc.client.Publish("hermes/injection/perform", 0, false, b)
+	tok := mc.client.Publish("hermes/injection/perform", mc.config.MQTTConfig.PubQoS(), false, b)
+
+	return tok.Error()
+}
+
+// PublishStatus publishes the retained status
+// to the status topic when one is configured
+func (mc mqttClient) PublishStatus(c mqtt.Client, status string) error {
+	topic := mc.config.MQTTConfig.StatusTopic
+	if topic == "" {
+		return nil
+	}
+
+	tok := c.Publish(topic, mc.config.MQTTConfig.PubQoS(), true, status)
+	tok.WaitTimeout(time.Second)
+
+	return tok.Error()
+}
+
+func PublishContinueSession(c mqtt.Client, qos byte, sessionID, text string, intents []string) error {
+	cont := model.ContinueSession{
+		Text:         text,
+		SessionID:    sessionID,
+		IntentFilter: intents,
+	}
+
+	cb, _ := json.Marshal(cont)
+
+	ch := "hermes/dialogueManager/continueSession"
+	tok := c.Publish(ch, qos, false, cb)
 
 	return tok.Error()
 }
 
-func PublishEndSession(c mqtt.Client, sessionID, text string) error {
+func PublishEndSession(c mqtt.Client, qos byte, sessionID, text string) error {
 	end := model.EndSession{
 		Text:      text,
 		SessionID: sessionID,
@@ -154,7 +380,7 @@ func PublishEndSession(c mqtt.Client, sessionID, text string) error {
 	eb, _ := json.Marshal(end)
 
 	ch := "hermes/dialogueManager/endSession"
-	tok := c.Publish(ch, 1, false, eb)
+	tok := c.Publish(ch, qos, false, eb)
 
 	return tok.Error()
 }
@@ -10,8 +10,17 @@ import (
 	"github.com/jnormington/snips-slack-pinger/model"
 )
 
-var testSlackHandlerFn = func(model.SlackConfig, string) error {
-	return nil
+type testNotifier struct {
+	resolveErr error
+	sendErr    error
+}
+
+func (n testNotifier) Resolve(name string, kind TargetKind) (Target, error) {
+	return Target{ID: name, Name: name, Kind: kind}, n.resolveErr
+}
+
+func (n testNotifier) Send(Target, string) error {
+	return n.sendErr
 }
 
 func TestNewMQTTClient(t *testing.T) {
@@ -26,7 +35,9 @@ func TestNewMQTTClient(t *testing.T) {
 		},
 	}
 
-	NewMQTTClient(conf, testSlackHandlerFn)
+	if _, err := NewMQTTClient(conf, testNotifier{}); err != nil {
+		t.Fatal(err)
+	}
 
 	t.Run("validate correct options passed", func(t *testing.T) {
 		defer func(fn func(*mqtt.ClientOptions) mqtt.Client) {
@@ -39,7 +50,9 @@ func TestNewMQTTClient(t *testing.T) {
 			return mqtt.NewClient(o)
 		}
 
-		NewMQTTClient(conf, testSlackHandlerFn)
+		if _, err := NewMQTTClient(conf, testNotifier{}); err != nil {
+			t.Fatal(err)
+		}
 
 		if opts == nil {
 			t.Fatal("expected opts to be supplied")
@@ -74,66 +87,242 @@ func TestNewMQTTClient(t *testing.T) {
 			}
 		}
 	})
-}
+	t.Run("no tls options", func(t *testing.T) {
+		defer func(fn func(*mqtt.ClientOptions) mqtt.Client) {
+			mqttClientFn = fn
+		}(mqttClientFn)
 
-func TestConnectToMQTTBroker(t *testing.T) {
-	defer func(i int) {
-		attempts = i
-	}(attempts)
+		var opts *mqtt.ClientOptions
+		mqttClientFn = func(o *mqtt.ClientOptions) mqtt.Client {
+			opts = o
+			return mqtt.NewClient(o)
+		}
+
+		if _, err := NewMQTTClient(conf, testNotifier{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if opts.TLSConfig != nil {
+			t.Fatalf("expected no tls config but got %+v", opts.TLSConfig)
+		}
+	})
+
+	t.Run("session options", func(t *testing.T) {
+		defer func(fn func(*mqtt.ClientOptions) mqtt.Client) {
+			mqttClientFn = fn
+		}(mqttClientFn)
+
+		var opts *mqtt.ClientOptions
+		mqttClientFn = func(o *mqtt.ClientOptions) mqtt.Client {
+			opts = o
+			return mqtt.NewClient(o)
+		}
+
+		clean := false
+		conf := conf
+		conf.MQTTConfig.ClientID = "snips-slack-pinger"
+		conf.MQTTConfig.CleanSession = &clean
+		conf.MQTTConfig.KeepAlive = model.Duration{Duration: time.Minute}
+		conf.MQTTConfig.ConnectTimeout = model.Duration{Duration: 10 * time.Second}
+		conf.MQTTConfig.StatusTopic = "snips-slack-pinger/status"
+
+		if _, err := NewMQTTClient(conf, testNotifier{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if opts.ClientID != "snips-slack-pinger" {
+			t.Errorf("expected client id snips-slack-pinger but got %q", opts.ClientID)
+		}
+
+		if opts.CleanSession {
+			t.Error("expected clean session to be disabled")
+		}
+
+		if opts.KeepAlive != 60 {
+			t.Errorf("expected keep alive 60s but got %ds", opts.KeepAlive)
+		}
+
+		if opts.ConnectTimeout != 10*time.Second {
+			t.Errorf("expected connect timeout 10s but got %s", opts.ConnectTimeout)
+		}
+
+		if !opts.WillEnabled || opts.WillTopic != "snips-slack-pinger/status" ||
+			string(opts.WillPayload) != "offline" || !opts.WillRetained || opts.WillQos != 1 {
+			t.Errorf("expected offline last will on status topic but got %q %q", opts.WillTopic, opts.WillPayload)
+		}
+	})
+
+	t.Run("default session options", func(t *testing.T) {
+		defer func(fn func(*mqtt.ClientOptions) mqtt.Client) {
+			mqttClientFn = fn
+		}(mqttClientFn)
+
+		var opts *mqtt.ClientOptions
+		mqttClientFn = func(o *mqtt.ClientOptions) mqtt.Client {
+			opts = o
+			return mqtt.NewClient(o)
+		}
+
+		if _, err := NewMQTTClient(conf, testNotifier{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if !opts.CleanSession || opts.KeepAlive != 30 || opts.ConnectTimeout != 5*time.Second {
+			t.Errorf("expected default session options but got clean %t keep alive %d connect timeout %s",
+				opts.CleanSession, opts.KeepAlive, opts.ConnectTimeout)
+		}
 
-	attempts = 1
+		if opts.WillEnabled {
+			t.Error("expected no last will without a status topic")
+		}
+	})
+
+	t.Run("tls options", func(t *testing.T) {
+		defer func(fn func(*mqtt.ClientOptions) mqtt.Client) {
+			mqttClientFn = fn
+		}(mqttClientFn)
+
+		var opts *mqtt.ClientOptions
+		mqttClientFn = func(o *mqtt.ClientOptions) mqtt.Client {
+			opts = o
+			return mqtt.NewClient(o)
+		}
+
+		conf := conf
+		conf.MQTTConfig.TLS = model.TLSConfig{ServerName: "broker.local", InsecureSkipVerify: true}
+
+		if _, err := NewMQTTClient(conf, testNotifier{}); err != nil {
+			t.Fatal(err)
+		}
 
+		if opts.TLSConfig == nil {
+			t.Fatal("expected tls config to be set")
+		}
+
+		if opts.TLSConfig.ServerName != "broker.local" || !opts.TLSConfig.InsecureSkipVerify {
+			t.Fatalf("expected tls options to be applied but got %+v", opts.TLSConfig)
+		}
+	})
+
+	t.Run("tls files failing to load", func(t *testing.T) {
+		conf := conf
+		conf.MQTTConfig.TLS = model.TLSConfig{CAFile: "testdata/missing-ca.pem"}
+
+		if _, err := NewMQTTClient(conf, testNotifier{}); err == nil {
+			t.Fatal("expected an error loading the tls files")
+		}
+	})
+}
+
+func TestConnectToMQTTBroker(t *testing.T) {
 	t.Run("error from token", func(t *testing.T) {
 		wantErr := errors.New("token test error")
-		mc := mqttClient{
-			client: testMQTTClient{
-				token: &testToken{err: wantErr},
-			},
-			errCh: make(chan error),
+		client := testMQTTClient{token: &testToken{err: wantErr}}
+		mc := buildTestClient(client)
+		mc.config.MQTTConfig.ConnectAttempts = 1
+
+		mc.ConnectToMQTTBroker()
+
+		if err := <-mc.errCh; err != ErrConnectFail {
+			t.Fatalf("expected error %q but got %q", ErrConnectFail, err)
 		}
 
-		go mc.ConnectToMQTTBroker()
+		if <-mc.connCh {
+			t.Fatal("expected not to be connected")
+		}
 
-		err := <-mc.errCh
-		if err != wantErr {
-			t.Fatalf("expected error %q but got %q", wantErr, err)
+		if mc.ConnState() != StateFailed {
+			t.Fatalf("expected state %s but got %s", StateFailed, mc.ConnState())
 		}
 	})
 
 	t.Run("error when not connected", func(t *testing.T) {
-		mc := mqttClient{
-			client: testMQTTClient{
-				connected: false,
-				token:     &testToken{},
-			},
-			errCh: make(chan error),
-		}
+		client := testMQTTClient{connected: false, token: &testToken{}}
+		mc := buildTestClient(client)
+		mc.config.MQTTConfig.ConnectAttempts = 3
+		mc.config.MQTTConfig.ConnectBackoff = model.Duration{Duration: time.Millisecond}
 
-		go mc.ConnectToMQTTBroker()
+		mc.ConnectToMQTTBroker()
 
-		err := <-mc.errCh
-		if err != ErrConnectFail {
+		if err := <-mc.errCh; err != ErrConnectFail {
 			t.Fatalf("expected error %q but got %q", ErrConnectFail, err)
 		}
+
+		if got := client.token.connects; got != 3 {
+			t.Fatalf("expected 3 connect attempts but got %d", got)
+		}
 	})
 
 	t.Run("connect success", func(t *testing.T) {
 		client := testMQTTClient{connected: true, token: &testToken{}}
-		mc := mqttClient{client: client, errCh: make(chan error)}
+		mc := buildTestClient(client)
+
+		var got []ConnState
+		mc.state.Observe(func(_, to ConnState) {
+			got = append(got, to)
+		})
 
-		go mc.ConnectToMQTTBroker()
+		mc.ConnectToMQTTBroker()
 
-		err := <-mc.errCh
-		if err != nil {
-			t.Fatalf("expected no error but got %q", err)
+		if !<-mc.connCh {
+			t.Fatal("expected to be connected")
 		}
 
 		if !client.token.connectCalled {
 			t.Fatal("expected connect to be called")
 		}
+
+		want := []ConnState{StateConnecting, StateConnected}
+		if !cmp.Equal(want, got) {
+			t.Fatal(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("retries until connected", func(t *testing.T) {
+		client := testMQTTClient{connected: true, token: &testToken{connectErrs: []error{
+			errors.New("connection refused"),
+			errors.New("connection refused"),
+		}}}
+
+		mc := buildTestClient(client)
+		mc.config.MQTTConfig.ConnectBackoff = model.Duration{Duration: time.Millisecond}
+
+		mc.ConnectToMQTTBroker()
+
+		if !<-mc.connCh {
+			t.Fatal("expected to be connected")
+		}
+
+		if got := client.token.connects; got != 3 {
+			t.Fatalf("expected 3 connect attempts but got %d", got)
+		}
 	})
 }
 
+func TestConnectionLost(t *testing.T) {
+	client := testMQTTClient{connected: true, token: &testToken{}}
+	mc := buildTestClient(client)
+
+	mc.ConnectToMQTTBroker()
+	<-mc.connCh
+
+	mc.ConnectionLostHandler(mc.client, errors.New("EOF"))
+	if mc.ConnState() != StateReconnecting {
+		t.Fatalf("expected state %s but got %s", StateReconnecting, mc.ConnState())
+	}
+
+	// Paho calls the connected handler once reconnected
+	mc.ConnectedHandler(mc.client)
+	if mc.ConnState() != StateConnected {
+		t.Fatalf("expected state %s but got %s", StateConnected, mc.ConnState())
+	}
+
+	want := "hermes/intent/slack-intent"
+	if !containsString(client.token.subscribed, want) {
+		t.Fatalf("expected resubscribe to %q but got %v", want, client.token.subscribed)
+	}
+}
+
 func TestConnectedHandler(t *testing.T) {
 	t.Run("subscribes to slack intent", func(t *testing.T) {
 		client := testMQTTClient{token: &testToken{}}
@@ -142,6 +331,90 @@ func TestConnectedHandler(t *testing.T) {
 		mc.ConnectedHandler(mc.client)
 
 		want := "hermes/intent/" + mc.config.SnipsConfig.SlackIntent
+		if client.token.subscribed[0] != want {
+			t.Fatal(cmp.Diff(want, client.token.subscribed[0]))
+		}
+	})
+
+	t.Run("subscribes to every routed intent", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildTestClient(client)
+		mc.config.SnipsConfig.Intents = []model.IntentConfig{
+			{Name: "standup-intent", Action: model.ActionStandupMessage},
+			{Name: "absent-intent", Action: model.ActionListAbsent},
+		}
+
+		mc.ConnectedHandler(mc.client)
+
+		want := []string{
+			"hermes/intent/slack-intent",
+			"hermes/intent/standup-intent",
+			"hermes/intent/absent-intent",
+			"hermes/injection/complete",
+			"hermes/injection/status",
+		}
+
+		if !cmp.Equal(want, client.token.subscribed) {
+			t.Fatal(cmp.Diff(want, client.token.subscribed))
+		}
+	})
+
+	t.Run("subscribes to refresh topic", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildTestClient(client)
+		mc.config.MQTTConfig.RefreshTopic = "snips-slack-pinger/refresh"
+
+		mc.ConnectedHandler(mc.client)
+
+		want := []string{
+			"hermes/intent/slack-intent",
+			"hermes/injection/complete",
+			"hermes/injection/status",
+			"snips-slack-pinger/refresh",
+		}
+		if !cmp.Equal(want, client.token.subscribed) {
+			t.Fatal(cmp.Diff(want, client.token.subscribed))
+		}
+	})
+
+	t.Run("subscribes with qos", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildTestClient(client)
+
+		qos := 2
+		mc.config.MQTTConfig.SubscribeQoS = &qos
+
+		mc.ConnectedHandler(mc.client)
+
+		if client.token.subscribeQoS != 2 {
+			t.Fatalf("expected subscribe qos 2 but got %d", client.token.subscribeQoS)
+		}
+	})
+
+	t.Run("publishes online status", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildTestClient(client)
+		mc.config.MQTTConfig.StatusTopic = "snips-slack-pinger/status"
+
+		mc.ConnectedHandler(mc.client)
+
+		if client.token.channel != "snips-slack-pinger/status" {
+			t.Fatal(cmp.Diff("snips-slack-pinger/status", client.token.channel))
+		}
+
+		last := client.token.messages[len(client.token.messages)-1]
+		if last != "online" {
+			t.Fatalf("expected online status but got %v", last)
+		}
+	})
+
+	t.Run("requests injection status", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildTestClient(client)
+
+		mc.ConnectedHandler(mc.client)
+
+		want := "hermes/injection/statusRequest"
 		if client.token.channel != want {
 			t.Fatal(cmp.Diff(want, client.token.channel))
 		}
@@ -186,7 +459,7 @@ func TestDefaultPublishHandler(t *testing.T) {
 		mc := buildTestClient(client)
 
 		mc.MessageHandler(mc.client, testMessage{
-			payload: []byte(`{"sessionId": "123", "customData": {}, "slots": [{"value": {"value": "someName"}}]}`),
+			payload: []byte(`{"sessionId": "123", "customData": {}, "intent": {"intentName": "slack-intent"}, "slots": [{"slotName": "slack_names", "value": {"value": "someName"}}]}`),
 		})
 
 		got := len(client.token.messages)
@@ -195,7 +468,7 @@ func TestDefaultPublishHandler(t *testing.T) {
 		}
 
 		gotMsg := string(client.token.messages[0].([]byte))
-		wantMsg := `{"sessionId":"123","text":"I've slacked someName"}`
+		wantMsg := `{"sessionId":"123","text":"I've messaged someName"}`
 		if gotMsg != wantMsg {
 			t.Fatal(cmp.Diff(wantMsg, gotMsg))
 		}
@@ -204,12 +477,10 @@ func TestDefaultPublishHandler(t *testing.T) {
 	t.Run("publishes end session", func(t *testing.T) {
 		client := testMQTTClient{token: &testToken{}}
 		mc := buildTestClient(client)
-		mc.slackHandler = func(model.SlackConfig, string) error {
-			return errors.New("no user found")
-		}
+		mc.notifier = testNotifier{resolveErr: errors.New("no user found")}
 
 		mc.MessageHandler(mc.client, testMessage{
-			payload: []byte(`{"sessionId": "123", "customData": {}, "slots": [{"value": {"value": "someName"}}]}`),
+			payload: []byte(`{"sessionId": "123", "customData": {}, "intent": {"intentName": "slack-intent"}, "slots": [{"slotName": "slack_names", "value": {"value": "someName"}}]}`),
 		})
 
 		got := len(client.token.messages)
@@ -224,12 +495,95 @@ func TestDefaultPublishHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("publishes end session when send fails", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildTestClient(client)
+		mc.notifier = testNotifier{sendErr: errors.New("channel_not_found")}
+
+		mc.MessageHandler(mc.client, testMessage{
+			payload: []byte(`{"sessionId": "123", "customData": {}, "intent": {"intentName": "slack-intent"}, "slots": [{"slotName": "slack_names", "value": {"value": "someName"}}]}`),
+		})
+
+		gotMsg := string(client.token.messages[0].([]byte))
+		wantMsg := `{"sessionId":"123","text":"channel_not_found"}`
+		if gotMsg != wantMsg {
+			t.Fatal(cmp.Diff(wantMsg, gotMsg))
+		}
+	})
+
+	t.Run("publishes end session for multiple slots", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildTestClient(client)
+
+		mc.MessageHandler(mc.client, testMessage{
+			payload: []byte(`{"sessionId": "123", "intent": {"intentName": "slack-intent"}, "slots": [
+				{"slotName": "slack_names", "value": {"value": "Alice"}},
+				{"slotName": "other_slot", "value": {"value": "Tuesday"}},
+				{"slotName": "slack_names", "value": {"value": "Bob"}}
+			]}`),
+		})
+
+		gotMsg := string(client.token.messages[0].([]byte))
+		wantMsg := `{"sessionId":"123","text":"I've messaged Alice and Bob"}`
+		if gotMsg != wantMsg {
+			t.Fatal(cmp.Diff(wantMsg, gotMsg))
+		}
+	})
+
+	t.Run("unknown intent", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildTestClient(client)
+
+		mc.MessageHandler(mc.client, testMessage{
+			payload: []byte(`{"sessionId": "123", "customData": {}, "intent": {"intentName": "other-intent"}}`),
+		})
+
+		gotMsg := string(client.token.messages[0].([]byte))
+		wantMsg := `{"sessionId":"123","text":"I don't know how to handle that intent"}`
+		if gotMsg != wantMsg {
+			t.Fatal(cmp.Diff(wantMsg, gotMsg))
+		}
+	})
+
+	t.Run("answer to another app's session", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildTestClient(client)
+		mc.config.SnipsConfig.ConfirmIntent = "shared:yes"
+		mc.config.SnipsConfig.CancelIntent = "shared:no"
+
+		mc.MessageHandler(mc.client, testMessage{
+			payload: []byte(`{"sessionId": "other-app", "customData": {}, "intent": {"intentName": "shared:yes"}}`),
+		})
+
+		if got := len(client.token.messages); got != 0 {
+			t.Fatalf("expected no message but got %d", got)
+		}
+	})
+
+	t.Run("routes to intent action", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildTestClient(client)
+		mc.config.SnipsConfig.Intents = []model.IntentConfig{
+			{Name: "standup-intent", Action: model.ActionStandupMessage, Channel: "general", Message: "Standup time"},
+		}
+
+		mc.MessageHandler(mc.client, testMessage{
+			payload: []byte(`{"sessionId": "123", "customData": {}, "intent": {"intentName": "standup-intent"}}`),
+		})
+
+		gotMsg := string(client.token.messages[0].([]byte))
+		wantMsg := `{"sessionId":"123","text":"I've posted the standup message to general"}`
+		if gotMsg != wantMsg {
+			t.Fatal(cmp.Diff(wantMsg, gotMsg))
+		}
+	})
+
 	t.Run("invalid number of slots", func(t *testing.T) {
 		client := testMQTTClient{token: &testToken{}}
 		mc := buildTestClient(client)
 
 		mc.MessageHandler(mc.client, testMessage{
-			payload: []byte(`{"sessionId": "123", "customData": {}}`),
+			payload: []byte(`{"sessionId": "123", "customData": {}, "intent": {"intentName": "slack-intent"}}`),
 		})
 
 		got := len(client.token.messages)
@@ -238,7 +592,7 @@ func TestDefaultPublishHandler(t *testing.T) {
 		}
 
 		gotMsg := string(client.token.messages[0].([]byte))
-		wantMsg := `{"sessionId":"123","text":"missing or too many slots from payload"}`
+		wantMsg := `{"sessionId":"123","text":"missing slots from payload"}`
 		if gotMsg != wantMsg {
 			t.Fatal(cmp.Diff(wantMsg, gotMsg))
 		}
@@ -249,7 +603,7 @@ func TestDefaultPublishHandler(t *testing.T) {
 		mc := buildTestClient(client)
 
 		mc.MessageHandler(mc.client, testMessage{
-			payload: []byte(`{"sessionId": "123", "customData": {}}`),
+			payload: []byte(`{"sessionId": "123", "customData": {}, "intent": {"intentName": "slack-intent"}}`),
 		})
 
 		got := len(client.token.messages)
@@ -258,7 +612,7 @@ func TestDefaultPublishHandler(t *testing.T) {
 		}
 
 		gotMsg := string(client.token.messages[0].([]byte))
-		wantMsg := `{"sessionId":"123","text":"missing or too many slots from payload"}`
+		wantMsg := `{"sessionId":"123","text":"missing slots from payload"}`
 		if gotMsg != wantMsg {
 			t.Fatal(cmp.Diff(wantMsg, gotMsg))
 		}
@@ -269,7 +623,7 @@ func TestDefaultPublishHandler(t *testing.T) {
 		mc := buildTestClient(client)
 
 		mc.MessageHandler(mc.client, testMessage{
-			payload: []byte(`{"sessionId": "123", "customData": {}, "slots": [{"value": {"value": "someName"}}]}`),
+			payload: []byte(`{"sessionId": "123", "customData": {}, "intent": {"intentName": "slack-intent"}, "slots": [{"slotName": "slack_names", "value": {"value": "someName"}}]}`),
 		})
 
 		got := len(client.token.messages)
@@ -278,13 +632,32 @@ func TestDefaultPublishHandler(t *testing.T) {
 		}
 
 		gotMsg := string(client.token.messages[0].([]byte))
-		wantMsg := `{"sessionId":"123","text":"I've slacked someName"}`
+		wantMsg := `{"sessionId":"123","text":"I've messaged someName"}`
 		if gotMsg != wantMsg {
 			t.Fatal(cmp.Diff(wantMsg, gotMsg))
 		}
 	})
 }
 
+func TestRequestRefresh(t *testing.T) {
+	mc := buildTestClient(testMQTTClient{token: &testToken{}})
+
+	mc.RefreshHandler(mc.client, testMessage{})
+	mc.RequestRefresh()
+
+	select {
+	case <-mc.refreshCh:
+	default:
+		t.Fatal("expected refresh to be requested")
+	}
+
+	select {
+	case <-mc.refreshCh:
+		t.Fatal("expected pending requests to be coalesced")
+	default:
+	}
+}
+
 func TestPublishEntity(t *testing.T) {
 	entity := &model.Entity{
 		Ops: [][]interface{}{
@@ -346,11 +719,32 @@ func TestPublishEntity(t *testing.T) {
 	})
 }
 
+func TestPublishContinueSession(t *testing.T) {
+	client := testMQTTClient{token: &testToken{}}
+
+	err := PublishContinueSession(client, 1, "1234", "Which Alex?", []string{"intent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotMsg := string(client.token.messages[0].([]byte))
+	wantMsg := `{"sessionId":"1234","text":"Which Alex?","intentFilter":["intent"]}`
+	if gotMsg != wantMsg {
+		t.Fatal(cmp.Diff(wantMsg, gotMsg))
+	}
+
+	gotCh := client.token.channel
+	wantCh := "hermes/dialogueManager/continueSession"
+	if gotCh != wantCh {
+		t.Fatal(cmp.Diff(wantCh, gotCh))
+	}
+}
+
 func TestPublishEndSession(t *testing.T) {
 	t.Run("publishes end session", func(t *testing.T) {
 		client := testMQTTClient{token: &testToken{}}
 
-		err := PublishEndSession(client, "1234", "Speak quote")
+		err := PublishEndSession(client, 1, "1234", "Speak quote")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -376,7 +770,7 @@ func TestPublishEndSession(t *testing.T) {
 	t.Run("publishes with error", func(t *testing.T) {
 		client := testMQTTClient{token: &testToken{err: errors.New("publish error")}}
 
-		err := PublishEndSession(client, "1234", "Some text")
+		err := PublishEndSession(client, 1, "1234", "Some text")
 		if err == nil {
 			t.Fatal("expected error but got none")
 		}
@@ -387,13 +781,22 @@ func buildTestClient(c testMQTTClient) mqttClient {
 	return mqttClient{
 		client: c,
 		config: model.Config{
+			SlackConfig: model.SlackConfig{
+				Messages: []string{"standup!"},
+			},
 			SnipsConfig: model.SnipsConfig{
 				SlackIntent: "slack-intent",
+				SlotName:    "slack_names",
 			},
 		},
-		errCh:        make(chan error),
-		connCh:       make(chan bool),
-		slackHandler: testSlackHandlerFn,
+		errCh:     make(chan error, 1),
+		connCh:    make(chan bool, 1),
+		refreshCh: make(chan struct{}, 1),
+		notifier:  testNotifier{},
+		sessions:  newSessionStore(0),
+
+		injections: newInjectionTracker(),
+		state:      newConnStateMachine(),
 	}
 }
 
@@ -405,7 +808,11 @@ type testMQTTClient struct {
 type testToken struct {
 	err           error
 	channel       string
+	subscribed    []string
 	connectCalled bool
+	connects      int
+	subscribeQoS  byte
+	connectErrs   []error
 	messages      []interface{}
 }
 
@@ -417,9 +824,19 @@ func (ft testToken) Wait() bool                     { return false }
 func (ft testToken) Error() error                   { return ft.err }
 func (ft testToken) WaitTimeout(time.Duration) bool { return false }
 
-func (f testMQTTClient) IsConnected() bool                    { return f.connected }
-func (f testMQTTClient) IsConnectionOpen() bool               { return false }
-func (f testMQTTClient) Connect() mqtt.Token                  { f.token.connectCalled = true; return f.token }
+func (f testMQTTClient) IsConnected() bool      { return f.connected }
+func (f testMQTTClient) IsConnectionOpen() bool { return false }
+func (f testMQTTClient) Connect() mqtt.Token {
+	f.token.connectCalled = true
+	f.token.connects++
+
+	// Each connect attempt fails with the next error
+	if f.token.connects <= len(f.token.connectErrs) {
+		return &testToken{err: f.token.connectErrs[f.token.connects-1]}
+	}
+
+	return f.token
+}
 func (f testMQTTClient) Disconnect(uint)                      {}
 func (f testMQTTClient) Unsubscribe(...string) mqtt.Token     { return f.token }
 func (f testMQTTClient) AddRoute(string, mqtt.MessageHandler) {}
@@ -428,9 +845,11 @@ func (f testMQTTClient) Publish(ch string, _ byte, _ bool, pl interface{}) mqtt.
 	f.token.channel = ch
 	return f.token
 }
-func (f testMQTTClient) Subscribe(c string, _ byte, _ mqtt.MessageHandler) mqtt.Token {
+func (f testMQTTClient) Subscribe(c string, qos byte, _ mqtt.MessageHandler) mqtt.Token {
 	if f.token.Error() == nil {
+		f.token.subscribeQoS = qos
 		f.token.channel = c
+		f.token.subscribed = append(f.token.subscribed, c)
 	}
 	return f.token
 }
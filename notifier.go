@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+// TargetKind describes what a resolved target is
+type TargetKind int
+
+const (
+	// TargetAny is only used when resolving to
+	// accept either a user or channel target
+	TargetAny TargetKind = iota
+	TargetUser
+	TargetChannel
+	// TargetUsergroup is a channel post
+	// mentioning a slack user group
+	TargetUsergroup
+)
+
+// Target holds a resolved user or channel
+// which a notifier is able to message
+type Target struct {
+	ID   string
+	Name string
+	Kind TargetKind
+
+	// Optional user names and mention syntax used
+	// when rendering messages, defaulting to Name
+	FirstName string
+	RealName  string
+	Mention   string
+}
+
+// Notifier resolves spoken names into targets and
+// sends them messages on a chat backend
+type Notifier interface {
+	// Resolve returns the target of kind matching the spoken
+	// name or an error which is spoken back to the user
+	Resolve(name string, kind TargetKind) (Target, error)
+	// Send posts text to the resolved target
+	Send(t Target, text string) error
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewNotifier builds the notifier for the backend selected
+// in the configuration, slack resolves names from dir
+func NewNotifier(c model.Config, dir *directory) (Notifier, error) {
+	switch c.NotifierType() {
+	case model.NotifierSlack:
+		return slackNotifier{config: c.SlackConfig, dir: dir}, nil
+	case model.NotifierWebhook:
+		return webhookNotifier{config: c.WebhookConfig}, nil
+	case model.NotifierMattermost:
+		return mattermostNotifier{config: c.MattermostConfig}, nil
+	}
+
+	return nil, fmt.Errorf("unknown notifier %q", c.Notifier)
+}
+
+// dryRunNotifier resolves targets with the wrapped
+// notifier but only logs who would have been messaged
+type dryRunNotifier struct {
+	Notifier
+}
+
+func (d dryRunNotifier) Send(t Target, text string) error {
+	log.Printf("[DRYRUN] Messaging user/channel %q with ID %q\n", t.Name, t.ID)
+	return nil
+}
+
+// Present checks presence with the wrapped notifier
+// as it only reads, nobody is messaged
+func (d dryRunNotifier) Present(t Target) (bool, error) {
+	pc, ok := d.Notifier.(presenceChecker)
+	if !ok {
+		return false, errPresenceUnsupported
+	}
+
+	return pc.Present(t)
+}
+
+// richSender is implemented by notifiers able to
+// post messages with attachments and blocks
+type richSender interface {
+	SendRich(t Target, m model.RichMessage) error
+}
+
+// sendMessage posts m to the target with the notifier, only
+// the plain text is sent by notifiers without rich messages
+func sendMessage(n Notifier, t Target, m model.RichMessage) error {
+	if rs, ok := n.(richSender); ok {
+		return rs.SendRich(t, m)
+	}
+
+	return n.Send(t, m.Text)
+}
+
+// presenceChecker is implemented by notifiers able
+// to report whether a resolved user is active
+type presenceChecker interface {
+	Present(t Target) (bool, error)
+}
+
+// notFoundError is returned when resolving
+// finds no target of kind matching name
+type notFoundError struct {
+	name string
+	kind TargetKind
+}
+
+func (e notFoundError) Error() string {
+	switch e.kind {
+	case TargetUser:
+		return fmt.Sprintf("I found no user called %s", e.name)
+	case TargetChannel:
+		return fmt.Sprintf("I found no channel called %s", e.name)
+	}
+
+	return fmt.Sprintf("I found no user or channel called %s", e.name)
+}
+
+// recipientKind returns the kind of a recipient
+// value, those prefixed with # are channels
+func recipientKind(id string) TargetKind {
+	if strings.HasPrefix(id, "#") {
+		return TargetChannel
+	}
+
+	return TargetUser
+}
+
+// resolveRecipient matches the spoken name against the keys of
+// recipients whose value is of kind, as given by kindFn
+func resolveRecipient(recipients map[string]string, name string, kind TargetKind, kindFn func(string) TargetKind) (Target, error) {
+	names := make([]string, 0, len(recipients))
+	for n, id := range recipients {
+		if kind == TargetAny || kindFn(id) == kind {
+			names = append(names, n)
+		}
+	}
+
+	sort.Strings(names)
+
+	match, err := newResolver(0, 0).resolveName(names, name)
+	if e, ok := err.(ambiguousMatchError); ok {
+		for _, c := range e.candidates {
+			id := recipients[c]
+			e.targets = append(e.targets, Target{ID: id, Name: c, Kind: kindFn(id)})
+		}
+
+		return Target{}, e
+	}
+
+	if err != nil {
+		return Target{}, err
+	}
+
+	if match == "" {
+		return Target{}, notFoundError{name: name, kind: kind}
+	}
+
+	id := recipients[match]
+	return Target{ID: id, Name: match, Kind: kindFn(id)}, nil
+}
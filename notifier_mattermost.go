@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+// mattermostPayload is the body accepted
+// by mattermost incoming webhooks
+type mattermostPayload struct {
+	Channel   string `json:"channel"`
+	Text      string `json:"text"`
+	Username  string `json:"username,omitempty"`
+	IconEmoji string `json:"icon_emoji,omitempty"`
+}
+
+// mattermostNotifier posts messages to a mattermost
+// incoming webhook overriding the channel per target
+type mattermostNotifier struct {
+	config model.MattermostConfig
+}
+
+func (n mattermostNotifier) Resolve(name string, kind TargetKind) (Target, error) {
+	t, err := resolveRecipient(n.config.Recipients, name, kind, mattermostKind)
+	if err != nil {
+		return t, err
+	}
+
+	t.Mention = t.ID
+	if t.Kind == TargetChannel {
+		t.Mention = "~" + strings.TrimPrefix(t.ID, "#")
+	}
+
+	return t, nil
+}
+
+// mattermostKind returns the kind of recipient, mattermost channels
+// are posted to by name so only @usernames are direct messages
+func mattermostKind(id string) TargetKind {
+	if strings.HasPrefix(id, "@") {
+		return TargetUser
+	}
+
+	return TargetChannel
+}
+
+func (n mattermostNotifier) Send(t Target, text string) error {
+	if t.Kind == TargetChannel {
+		text = "@here " + text
+	}
+
+	b, _ := json.Marshal(mattermostPayload{
+		Channel:   strings.TrimPrefix(t.ID, "#"),
+		Text:      text,
+		Username:  n.config.Username,
+		IconEmoji: n.config.EmojiIcon,
+	})
+
+	req, err := http.NewRequest(http.MethodPost, n.config.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	log.Printf("Messaging user/channel %q with ID %q\n", t.Name, t.ID)
+	return postWebhook(req)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+func TestMattermostNotifierResolve(t *testing.T) {
+	n := mattermostNotifier{config: model.MattermostConfig{
+		Recipients: map[string]string{
+			"Jodie Foster": "@jodie",
+			"dev ops":      "devops",
+		},
+	}}
+
+	specs := []struct {
+		in   string
+		want Target
+	}{
+		{"jody foster", Target{ID: "@jodie", Name: "Jodie Foster", Kind: TargetUser, Mention: "@jodie"}},
+		{"dev ops", Target{ID: "devops", Name: "dev ops", Kind: TargetChannel, Mention: "~devops"}},
+	}
+
+	for _, s := range specs {
+		got, err := n.Resolve(s.in, TargetAny)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != s.want {
+			t.Error(cmp.Diff(s.want, got))
+		}
+	}
+}
+
+func TestMattermostNotifierSend(t *testing.T) {
+	var gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		gotBody = string(b)
+	}))
+	defer srv.Close()
+
+	n := mattermostNotifier{config: model.MattermostConfig{
+		URL:       srv.URL,
+		Username:  "Standup bot",
+		EmojiIcon: ":point_right:",
+	}}
+
+	specs := []struct {
+		target Target
+		want   string
+	}{
+		{
+			Target{ID: "@jodie", Kind: TargetUser},
+			`{"channel":"@jodie","text":"standup!","username":"Standup bot","icon_emoji":":point_right:"}`,
+		},
+		{
+			Target{ID: "#devops", Kind: TargetChannel},
+			`{"channel":"devops","text":"@here standup!","username":"Standup bot","icon_emoji":":point_right:"}`,
+		},
+	}
+
+	for _, s := range specs {
+		if err := n.Send(s.target, "standup!"); err != nil {
+			t.Fatal(err)
+		}
+
+		if gotBody != s.want {
+			t.Error(cmp.Diff(s.want, gotBody))
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/bluele/slack"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+// slackNotifier resolves users and channels from
+// the slack directory and posts messages to them
+type slackNotifier struct {
+	config model.SlackConfig
+	dir    *directory
+}
+
+func (n slackNotifier) Resolve(name string, kind TargetKind) (Target, error) {
+	if kind != TargetChannel {
+		u, err := n.resolveUser(name)
+		if err != nil {
+			return Target{}, err
+		}
+
+		if u != nil {
+			return userTarget(u), nil
+		}
+
+		if kind == TargetUser {
+			return Target{}, notFoundError{name: name, kind: kind}
+		}
+	}
+
+	if c, ok := n.resolveChannel(name); ok && !n.config.IsBlacklisted(c.Id) {
+		return Target{ID: c.Id, Name: c.Name, Kind: TargetChannel, Mention: "<#" + c.Id + ">"}, nil
+	}
+
+	if kind != TargetUser {
+		if g, ok := n.resolveUsergroup(name); ok && !n.config.IsBlacklisted(g.ID) {
+			return n.usergroupTarget(g)
+		}
+	}
+
+	return Target{}, notFoundError{name: name, kind: kind}
+}
+
+// userTarget returns the target messaging the slack user
+func userTarget(u *slack.User) Target {
+	t := Target{ID: u.Id, Name: displayName(u), Kind: TargetUser, Mention: "<@" + u.Id + ">"}
+	if u.Profile != nil {
+		t.FirstName = u.Profile.FirstName
+		t.RealName = u.Profile.RealName
+	}
+
+	return t
+}
+
+// resolveChannel looks up the channel by name
+// or by any of the configured spoken aliases
+func (n slackNotifier) resolveChannel(name string) (*slack.Channel, bool) {
+	if c, ok := n.dir.ChannelByName(name); ok {
+		return c, true
+	}
+
+	key := channelKey(name)
+	for ch, aliases := range n.config.ChannelAliases {
+		for _, a := range aliases {
+			if channelKey(a) == key {
+				return n.dir.ChannelByName(ch)
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// resolveUsergroup looks up the user group by name, handle
+// or any of the spoken aliases configured for channels
+func (n slackNotifier) resolveUsergroup(name string) (slackUsergroup, bool) {
+	if g, ok := n.dir.UsergroupByName(name); ok {
+		return g, true
+	}
+
+	key := channelKey(name)
+	for g, aliases := range n.config.ChannelAliases {
+		for _, a := range aliases {
+			if channelKey(a) == key {
+				return n.dir.UsergroupByName(g)
+			}
+		}
+	}
+
+	return slackUsergroup{}, false
+}
+
+// resolveUser looks up a single user with the exact real name
+// before falling back to fuzzy matching against every user
+// by their names and configured aliases
+func (n slackNotifier) resolveUser(name string) (*slack.User, error) {
+	var exact []*slack.User
+	for _, u := range n.dir.UsersByName(name) {
+		if !n.config.IsBlacklisted(u.Id) {
+			exact = append(exact, u)
+		}
+	}
+
+	if len(exact) == 1 {
+		return exact[0], nil
+	}
+
+	r := newResolver(n.config.MatchThreshold, n.config.AmbiguityMargin)
+	profiles := n.dir.Profiles()
+	r.aliases = func(u *slack.User) []string {
+		return n.config.UserAliases(u, profiles)
+	}
+	return r.resolveUser(n.dir.Users(), name, n.config.IsBlacklisted)
+}
+
+func (n slackNotifier) Send(t Target, text string) error {
+	return n.SendRich(t, model.RichMessage{Text: text})
+}
+
+// SendRich posts the message with any attachments and blocks,
+// the text is the fallback shown in notifications. User groups
+// are mentioned first unless the text already mentions them.
+func (n slackNotifier) SendRich(t Target, m model.RichMessage) error {
+	text := m.Text
+	switch t.Kind {
+	case TargetChannel:
+		text = "@here " + text
+	case TargetUsergroup:
+		if !strings.Contains(text, t.Mention) {
+			text = t.Mention + " " + text
+		}
+	}
+
+	uv := url.Values{}
+	uv.Add("channel", t.ID)
+	uv.Add("text", text)
+	uv.Add("link_names", "true")
+
+	if n.config.Username != "" {
+		uv.Add("username", n.config.Username)
+	}
+
+	if n.config.EmojiIcon != "" {
+		uv.Add("icon_emoji", n.config.EmojiIcon)
+	}
+
+	if len(m.Attachments) > 0 {
+		uv.Add("attachments", string(m.Attachments))
+	}
+
+	if len(m.Blocks) > 0 {
+		uv.Add("blocks", string(m.Blocks))
+	}
+
+	log.Printf("Messaging user/channel %q with ID %q\n", t.Name, t.ID)
+	_, err := slackPost("chat.postMessage", n.config.Token, uv, slackMessageTimeout)
+	return err
+}
+
+// presenceResponse is the response of users.getPresence
+type presenceResponse struct {
+	Presence string `json:"presence"`
+}
+
+// Present reports whether the slack user is active
+func (n slackNotifier) Present(t Target) (bool, error) {
+	uv := url.Values{}
+	uv.Add("user", t.ID)
+
+	body, err := slackGet("users.getPresence", n.config.Token, uv, slackMessageTimeout)
+	if err != nil {
+		return false, err
+	}
+
+	var res presenceResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return false, err
+	}
+
+	return res.Presence == "active", nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bluele/slack"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+func TestSlackNotifierResolve(t *testing.T) {
+	dir := newDirectory()
+	dir.SetUsers([]*slack.User{
+		{Id: "U1", Name: "jfoster", Profile: &slack.ProfileInfo{FirstName: "Jodie", RealName: "Jodie Foster"}},
+		{Id: "U2", Name: "ahopkins", Profile: &slack.ProfileInfo{RealName: "Anthony Hopkins"}},
+		{Id: "U3", Name: "tlevine", Profile: &slack.ProfileInfo{RealName: "Ted Levine"}},
+		{Id: "U4", Name: "tlevine2", Profile: &slack.ProfileInfo{RealName: "Ted Levine"}},
+		{Id: "U5", Name: "sglenn", Profile: &slack.ProfileInfo{RealName: "Scott Glenn"}},
+	})
+	dir.SetProfiles(model.Profiles{"U5": {"display_name": "Scotty"}})
+
+	dir.SetChannels([]*slack.Channel{
+		{Id: "C1", Name: "devops"},
+		{Id: "C2", Name: "general"},
+		{Id: "C3", Name: "sre"},
+	})
+
+	n := slackNotifier{
+		config: model.SlackConfig{
+			Blacklist:         []string{"U2", "U4", "C2"},
+			ChannelAliases:    map[string][]string{"sre": {"site reliability"}},
+			Aliases:           map[string][]string{"U1": {"JF"}, "tlevine": {"Teddy"}},
+			AliasProfileField: "display_name",
+		},
+		dir: dir,
+	}
+
+	specs := []struct {
+		in      string
+		kind    TargetKind
+		want    Target
+		wantErr string
+	}{
+		{"Jody Foster", TargetAny, Target{ID: "U1", Name: "Jodie Foster", Kind: TargetUser, FirstName: "Jodie", RealName: "Jodie Foster", Mention: "<@U1>"}, ""},
+		{"DevOps", TargetAny, Target{ID: "C1", Name: "devops", Kind: TargetChannel, Mention: "<#C1>"}, ""},
+		{"Anthony Hopkins", TargetAny, Target{}, "I found no user or channel called Anthony Hopkins"},
+		{"general", TargetAny, Target{}, "I found no user or channel called general"},
+		{"Jodie Foster", TargetUser, Target{ID: "U1", Name: "Jodie Foster", Kind: TargetUser, FirstName: "Jodie", RealName: "Jodie Foster", Mention: "<@U1>"}, ""},
+		{"devops", TargetUser, Target{}, "I found no user called devops"},
+		{"Jodie Foster", TargetChannel, Target{}, "I found no channel called Jodie Foster"},
+		{"#devops", TargetChannel, Target{ID: "C1", Name: "devops", Kind: TargetChannel, Mention: "<#C1>"}, ""},
+		{"dev ops", TargetChannel, Target{ID: "C1", Name: "devops", Kind: TargetChannel, Mention: "<#C1>"}, ""},
+		{"Site Reliability", TargetAny, Target{ID: "C3", Name: "sre", Kind: TargetChannel, Mention: "<#C3>"}, ""},
+		{"ted levine", TargetUser, Target{ID: "U3", Name: "Ted Levine", Kind: TargetUser, RealName: "Ted Levine", Mention: "<@U3>"}, ""},
+		{"J.F.", TargetAny, Target{ID: "U1", Name: "Jodie Foster", Kind: TargetUser, FirstName: "Jodie", RealName: "Jodie Foster", Mention: "<@U1>"}, ""},
+		{"teddy", TargetUser, Target{ID: "U3", Name: "Ted Levine", Kind: TargetUser, RealName: "Ted Levine", Mention: "<@U3>"}, ""},
+		{"Scotty", TargetAny, Target{ID: "U5", Name: "Scott Glenn", Kind: TargetUser, RealName: "Scott Glenn", Mention: "<@U5>"}, ""},
+	}
+
+	for _, s := range specs {
+		got, err := n.Resolve(s.in, s.kind)
+
+		var gotErr string
+		if err != nil {
+			gotErr = err.Error()
+		}
+
+		if gotErr != s.wantErr {
+			t.Errorf("expected error %q but got %q", s.wantErr, gotErr)
+		}
+
+		if got != s.want {
+			t.Error(cmp.Diff(s.want, got))
+		}
+	}
+}
+
+func TestSlackNotifierSend(t *testing.T) {
+	var (
+		gotPath string
+		gotAuth string
+		gotForm url.Values
+		resp    = `{"ok": true}`
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		gotPath, gotAuth, gotForm = r.URL.Path, r.Header.Get("Authorization"), r.PostForm
+		w.Write([]byte(resp))
+	}))
+	defer srv.Close()
+
+	defer func(u string) { slackAPIURL = u }(slackAPIURL)
+	slackAPIURL = srv.URL + "/api/"
+
+	n := slackNotifier{config: model.SlackConfig{
+		Token:     "xoxb-1234",
+		Username:  "Standup bot",
+		EmojiIcon: ":point_right:",
+	}}
+
+	t.Run("plain text", func(t *testing.T) {
+		if err := n.Send(Target{ID: "C1", Kind: TargetChannel}, "standup!"); err != nil {
+			t.Fatal(err)
+		}
+
+		want := url.Values{
+			"channel":    {"C1"},
+			"text":       {"@here standup!"},
+			"link_names": {"true"},
+			"username":   {"Standup bot"},
+			"icon_emoji": {":point_right:"},
+		}
+
+		if gotPath != "/api/chat.postMessage" {
+			t.Errorf("expected chat.postMessage but got %q", gotPath)
+		}
+
+		if gotAuth != "Bearer xoxb-1234" {
+			t.Errorf("expected the token in the Authorization header but got %q", gotAuth)
+		}
+
+		if !cmp.Equal(want, gotForm) {
+			t.Error(cmp.Diff(want, gotForm))
+		}
+	})
+
+	t.Run("rich message", func(t *testing.T) {
+		m := model.RichMessage{
+			Text:        "standup!",
+			Attachments: json.RawMessage(`[{"text":"standup"}]`),
+			Blocks:      json.RawMessage(`[{"type":"divider"}]`),
+		}
+
+		if err := n.SendRich(Target{ID: "U1", Kind: TargetUser}, m); err != nil {
+			t.Fatal(err)
+		}
+
+		for k, want := range map[string]string{"text": "standup!", "attachments": `[{"text":"standup"}]`, "blocks": `[{"type":"divider"}]`} {
+			if got := gotForm.Get(k); got != want {
+				t.Errorf("expected %s %q but got %q", k, want, got)
+			}
+		}
+	})
+
+	t.Run("user group", func(t *testing.T) {
+		if err := n.Send(Target{ID: "C1", Kind: TargetUsergroup, Mention: "<!subteam^S1>"}, "standup!"); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := gotForm.Get("text"), "<!subteam^S1> standup!"; got != want {
+			t.Errorf("expected text %q but got %q", want, got)
+		}
+	})
+
+	t.Run("slack error", func(t *testing.T) {
+		resp = `{"ok": false, "error": "invalid_blocks"}`
+
+		err := n.SendRich(Target{ID: "U1"}, model.RichMessage{Text: "standup!", Blocks: json.RawMessage(`[{}]`)})
+		if err == nil || err.Error() != "slack failed with invalid_blocks" {
+			t.Errorf("expected invalid_blocks error but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+func TestNewNotifier(t *testing.T) {
+	specs := []struct {
+		notifier string
+		want     Notifier
+	}{
+		{"", slackNotifier{}},
+		{model.NotifierSlack, slackNotifier{}},
+		{model.NotifierWebhook, webhookNotifier{}},
+		{model.NotifierMattermost, mattermostNotifier{}},
+	}
+
+	for _, s := range specs {
+		got, err := NewNotifier(model.Config{Notifier: s.notifier}, nil)
+		if err != nil {
+			t.Fatal("expected no error but got", err)
+		}
+
+		if reflect.TypeOf(got) != reflect.TypeOf(s.want) {
+			t.Errorf("expected notifier %T but got %T", s.want, got)
+		}
+	}
+
+	t.Run("unknown notifier", func(t *testing.T) {
+		_, err := NewNotifier(model.Config{Notifier: "irc"}, nil)
+		if err == nil {
+			t.Fatal("expected error but got none")
+		}
+	})
+}
+
+func TestDryRunNotifier(t *testing.T) {
+	n := dryRunNotifier{testNotifier{}}
+
+	got, err := n.Resolve("someName", TargetAny)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.ID != "someName" {
+		t.Errorf("expected wrapped notifier to resolve target but got %q", got.ID)
+	}
+
+	if err := n.Send(got, "standup!"); err != nil {
+		t.Fatal("expected no error but got", err)
+	}
+}
+
+func TestResolveRecipient(t *testing.T) {
+	recipients := map[string]string{
+		"Jodie Foster": "@jodie",
+		"dev ops":      "#devops",
+	}
+
+	t.Run("user recipient", func(t *testing.T) {
+		got, err := resolveRecipient(recipients, "jody foster", TargetAny, recipientKind)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := Target{ID: "@jodie", Name: "Jodie Foster", Kind: TargetUser}
+		if got != want {
+			t.Error(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("channel recipient", func(t *testing.T) {
+		got, err := resolveRecipient(recipients, "devops", TargetAny, recipientKind)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := Target{ID: "#devops", Name: "dev ops", Kind: TargetChannel}
+		if got != want {
+			t.Error(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("no recipient", func(t *testing.T) {
+		_, err := resolveRecipient(recipients, "brooke smith", TargetAny, recipientKind)
+		if err == nil {
+			t.Fatal("expected error but got none")
+		}
+
+		want := "I found no user or channel called brooke smith"
+		if err.Error() != want {
+			t.Fatalf("expected error %q but got %q", want, err)
+		}
+	})
+
+	t.Run("recipient of wrong kind", func(t *testing.T) {
+		_, err := resolveRecipient(recipients, "devops", TargetUser, recipientKind)
+		if err == nil {
+			t.Fatal("expected error but got none")
+		}
+
+		want := "I found no user called devops"
+		if err.Error() != want {
+			t.Fatalf("expected error %q but got %q", want, err)
+		}
+	})
+	t.Run("ambiguous recipient", func(t *testing.T) {
+		recipients := map[string]string{"Jon Smith": "@jon", "Jan Smith": "@jan"}
+
+		_, err := resolveRecipient(recipients, "jen smith", TargetAny, recipientKind)
+		e, ok := err.(ambiguousMatchError)
+		if !ok {
+			t.Fatalf("expected ambiguous match error but got %v", err)
+		}
+
+		want := []Target{{ID: "@jan", Name: "Jan Smith", Kind: TargetUser}, {ID: "@jon", Name: "Jon Smith", Kind: TargetUser}}
+		if !cmp.Equal(want, e.targets) {
+			t.Error(cmp.Diff(want, e.targets))
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+// webhookPayload is the json body posted
+// to the generic webhook for each message
+type webhookPayload struct {
+	Target string `json:"target"`
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Text   string `json:"text"`
+}
+
+// webhookNotifier posts messages as json
+// to a generic http webhook endpoint
+type webhookNotifier struct {
+	config model.WebhookConfig
+}
+
+func (n webhookNotifier) Resolve(name string, kind TargetKind) (Target, error) {
+	if len(n.config.Recipients) == 0 {
+		if kind == TargetAny {
+			kind = TargetUser
+		}
+
+		return Target{ID: name, Name: name, Kind: kind}, nil
+	}
+
+	return resolveRecipient(n.config.Recipients, name, kind, recipientKind)
+}
+
+func (n webhookNotifier) Send(t Target, text string) error {
+	kind := "user"
+	if t.Kind == TargetChannel {
+		kind = "channel"
+	}
+
+	b, _ := json.Marshal(webhookPayload{
+		Target: t.ID,
+		Name:   t.Name,
+		Kind:   kind,
+		Text:   text,
+	})
+
+	req, err := http.NewRequest(http.MethodPost, n.config.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range n.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	log.Printf("Messaging user/channel %q with ID %q\n", t.Name, t.ID)
+	return postWebhook(req)
+}
+
+// postWebhook sends the request and returns an
+// error when the response isn't successful
+func postWebhook(req *http.Request) error {
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("webhook responded with status %d: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+func TestWebhookNotifierResolve(t *testing.T) {
+	t.Run("without recipients", func(t *testing.T) {
+		n := webhookNotifier{}
+
+		got, err := n.Resolve("Jodie Foster", TargetAny)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := Target{ID: "Jodie Foster", Name: "Jodie Foster", Kind: TargetUser}
+		if got != want {
+			t.Error(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("with recipients", func(t *testing.T) {
+		n := webhookNotifier{config: model.WebhookConfig{
+			Recipients: map[string]string{"Jodie Foster": "jodie@example.com"},
+		}}
+
+		got, err := n.Resolve("jody foster", TargetAny)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := Target{ID: "jodie@example.com", Name: "Jodie Foster", Kind: TargetUser}
+		if got != want {
+			t.Error(cmp.Diff(want, got))
+		}
+	})
+}
+
+func TestWebhookNotifierSend(t *testing.T) {
+	t.Run("posts json payload", func(t *testing.T) {
+		var gotBody, gotAuth, gotType string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			gotBody = string(b)
+			gotAuth = r.Header.Get("Authorization")
+			gotType = r.Header.Get("Content-Type")
+		}))
+		defer srv.Close()
+
+		n := webhookNotifier{config: model.WebhookConfig{
+			URL:     srv.URL,
+			Headers: map[string]string{"Authorization": "Bearer 1234"},
+		}}
+
+		err := n.Send(Target{ID: "#devops", Name: "dev ops", Kind: TargetChannel}, "standup!")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := `{"target":"#devops","name":"dev ops","kind":"channel","text":"standup!"}`
+		if gotBody != want {
+			t.Error(cmp.Diff(want, gotBody))
+		}
+
+		if gotAuth != "Bearer 1234" {
+			t.Errorf("expected authorization header but got %q", gotAuth)
+		}
+
+		if gotType != "application/json" {
+			t.Errorf("expected json content type but got %q", gotType)
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+		}))
+		defer srv.Close()
+
+		n := webhookNotifier{config: model.WebhookConfig{URL: srv.URL}}
+
+		err := n.Send(Target{ID: "jodie", Name: "jodie"}, "standup!")
+		if err == nil {
+			t.Fatal("expected error but got none")
+		}
+
+		want := "webhook responded with status 401: bad token"
+		if err.Error() != want {
+			t.Fatalf("expected error %q but got %q", want, err)
+		}
+	})
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/bluele/slack"
+)
+
+const (
+	// Used when the threshold or margin isn't configured
+	defaultMatchThreshold  = 0.8
+	defaultAmbiguityMargin = 0.05
+
+	// Boost given, up to phoneticMaxScore, when the phonetic codes
+	// of every word match and the spelling is already at least
+	// phoneticMinSimilarity alike, so short names sounding alike
+	// such as "Sam" and "Sean" don't match
+	phoneticBoost         = 0.1
+	phoneticMinSimilarity = 0.7
+	phoneticMaxScore      = 0.9
+	// Score given when the names only differ by spacing
+	// or punctuation, i.e "jt" and "j.t"
+	compactScore = 0.95
+)
+
+// accentFold maps common latin accented characters
+// to their unaccented equivalent
+var accentFold = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae",
+	'ç': "c", 'č': "c", 'ć': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ě': "e", 'ę': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'œ': "oe",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ů': "u",
+	'ý': "y", 'ÿ': "y",
+	'ß': "ss", 'ł': "l", 'ř': "r", 'š': "s", 'ś': "s", 'ž': "z", 'ź': "z", 'ż': "z",
+}
+
+// ambiguousMatchError is returned when more than one
+// candidate scores similarly for the name requested
+type ambiguousMatchError struct {
+	name       string
+	candidates []string
+
+	// targets are the candidates resolved, in the same order,
+	// so a choice is messaged without resolving it again
+	targets []Target
+}
+
+func (e ambiguousMatchError) Error() string {
+	return fmt.Sprintf("I found more than one match for %s: %s", e.name, strings.Join(e.candidates, " or "))
+}
+
+// resolver scores candidates against a spoken
+// name and picks the best candidate
+type resolver struct {
+	threshold float64
+	margin    float64
+
+	// aliases optionally returns the spoken
+	// aliases a user is also matched against
+	aliases func(u *slack.User) []string
+}
+
+// match holds the score of a candidate and
+// its index in the list being resolved
+type match struct {
+	index int
+	name  string
+	score float64
+}
+
+func newResolver(threshold, margin float64) resolver {
+	r := resolver{
+		threshold: threshold,
+		margin:    margin,
+	}
+
+	if r.threshold == 0 {
+		r.threshold = defaultMatchThreshold
+	}
+
+	if r.margin == 0 {
+		r.margin = defaultAmbiguityMargin
+	}
+
+	return r
+}
+
+// resolveUser returns the user best matching name, nil when no user
+// scored above the threshold or ambiguousMatchError when several
+// users scored within the ambiguity margin of the best match
+func (r resolver) resolveUser(users []*slack.User, name string, skip func(string) bool) (*slack.User, error) {
+	var matches []match
+
+	for i, u := range users {
+		if u == nil || u.Deleted || skip(u.Id) {
+			continue
+		}
+
+		var aliases []string
+		if r.aliases != nil {
+			aliases = r.aliases(u)
+		}
+
+		matches = append(matches, match{index: i, name: displayName(u), score: scoreUser(u, name, aliases...)})
+	}
+
+	top := r.best(matches)
+	switch len(top) {
+	case 0:
+		return nil, nil
+	case 1:
+		return users[top[0].index], nil
+	}
+
+	err := ambiguousMatchError{name: name}
+	for _, m := range top {
+		err.targets = append(err.targets, userTarget(users[m.index]))
+	}
+
+	err.candidates = distinctLabels(err.targets, func(i int) string {
+		return users[top[i].index].Name
+	})
+
+	return nil, err
+}
+
+// resolveName returns the candidate best matching name, an empty
+// string when none scored above the threshold or ambiguousMatchError
+// when several scored within the ambiguity margin of the best match
+func (r resolver) resolveName(candidates []string, name string) (string, error) {
+	var matches []match
+
+	for i, c := range candidates {
+		matches = append(matches, match{index: i, name: c, score: scoreName(name, c)})
+	}
+
+	top := r.best(matches)
+	switch len(top) {
+	case 0:
+		return "", nil
+	case 1:
+		return candidates[top[0].index], nil
+	}
+
+	err := ambiguousMatchError{name: name}
+	for _, m := range top {
+		err.candidates = append(err.candidates, m.name)
+	}
+
+	return "", err
+}
+
+// best returns the highest scoring match along with any scoring
+// within the ambiguity margin of it, none scored above the threshold
+func (r resolver) best(matches []match) []match {
+	var scored []match
+
+	for _, m := range matches {
+		if m.score >= r.threshold {
+			scored = append(scored, m)
+		}
+	}
+
+	if len(scored) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+
+	top := scored[:1]
+	for _, m := range scored[1:] {
+		if scored[0].score-m.score < r.margin {
+			top = append(top, m)
+		}
+	}
+
+	return top
+}
+
+// distinctLabels returns the spoken label of each target, its name
+// unless another target shares it when it's the target's handle
+func distinctLabels(targets []Target, handle func(i int) string) []string {
+	seen := map[string]int{}
+	for _, t := range targets {
+		seen[normalizeName(t.Name)]++
+	}
+
+	labels := make([]string, len(targets))
+	for i, t := range targets {
+		labels[i] = t.Name
+		if seen[normalizeName(t.Name)] > 1 {
+			labels[i] = handle(i)
+		}
+	}
+
+	return labels
+}
+
+// scoreUser returns the highest score of name against the user's
+// real name, first name, last name, handle and any aliases
+func scoreUser(u *slack.User, name string, aliases ...string) float64 {
+	fields := append([]string{u.Name}, aliases...)
+	if u.Profile != nil {
+		fields = append(fields, u.Profile.RealName, u.Profile.FirstName, u.Profile.LastName)
+	}
+
+	var best float64
+	for _, f := range fields {
+		if s := scoreName(name, f); s > best {
+			best = s
+		}
+	}
+
+	return best
+}
+
+// scoreName returns a score between 0 and 1 of how
+// closely the spoken name matches the candidate
+func scoreName(name, candidate string) float64 {
+	n, c := normalizeName(name), normalizeName(candidate)
+	if n == "" || c == "" {
+		return 0
+	}
+
+	if n == c {
+		return 1
+	}
+
+	if strings.Replace(n, " ", "", -1) == strings.Replace(c, " ", "", -1) {
+		return compactScore
+	}
+
+	score := similarity(n, c)
+	if score >= phoneticMinSimilarity && score < phoneticMaxScore && phoneticMatch(n, c) {
+		score = math.Min(score+phoneticBoost, phoneticMaxScore)
+	}
+
+	return score
+}
+
+// normalizeName lower cases, folds accents and replaces
+// any punctuation with a single space between words
+func normalizeName(s string) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(s) {
+		if f, ok := accentFold[r]; ok {
+			b.WriteString(f)
+			continue
+		}
+
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			continue
+		}
+
+		b.WriteRune(' ')
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// similarity returns the levenshtein distance of a and b
+// as a ratio of the longest string, 1 being identical
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+
+	if longest == 0 {
+		return 1
+	}
+
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+func minInt(v int, vs ...int) int {
+	for _, n := range vs {
+		if n < v {
+			v = n
+		}
+	}
+
+	return v
+}
+
+// phoneticMatch reports whether both names have the same
+// number of words and each word has the same soundex code
+func phoneticMatch(a, b string) bool {
+	wa, wb := strings.Fields(a), strings.Fields(b)
+	if len(wa) != len(wb) {
+		return false
+	}
+
+	for i := range wa {
+		if soundex(wa[i]) != soundex(wb[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// soundex returns the american soundex code for
+// the word, non ascii letters are ignored
+func soundex(word string) string {
+	codes := map[rune]byte{
+		'b': '1', 'f': '1', 'p': '1', 'v': '1',
+		'c': '2', 'g': '2', 'j': '2', 'k': '2', 'q': '2', 's': '2', 'x': '2', 'z': '2',
+		'd': '3', 't': '3',
+		'l': '4',
+		'm': '5', 'n': '5',
+		'r': '6',
+	}
+
+	var (
+		out  []byte
+		last byte
+	)
+
+	for _, r := range word {
+		if r < 'a' || r > 'z' {
+			continue
+		}
+
+		code := codes[r]
+
+		if len(out) == 0 {
+			out = append(out, byte(unicode.ToUpper(r)))
+			last = code
+			continue
+		}
+
+		switch {
+		case r == 'h' || r == 'w':
+			// h and w don't separate letters with the same code
+		case code == 0:
+			last = 0
+		case code != last:
+			out = append(out, code)
+			last = code
+		}
+
+		if len(out) == 4 {
+			break
+		}
+	}
+
+	if len(out) == 0 {
+		return ""
+	}
+
+	for len(out) < 4 {
+		out = append(out, '0')
+	}
+
+	return string(out)
+}
+
+func displayName(u *slack.User) string {
+	if u.Profile != nil && u.Profile.RealName != "" {
+		return u.Profile.RealName
+	}
+
+	return u.Name
+}
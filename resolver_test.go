@@ -0,0 +1,233 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bluele/slack"
+)
+
+func TestResolveUser(t *testing.T) {
+	users := []*slack.User{
+		{Id: "U1", Name: "jfoster", Profile: &slack.ProfileInfo{RealName: "Jodie Foster", FirstName: "Jodie", LastName: "Foster"}},
+		{Id: "U2", Name: "ahopkins", Profile: &slack.ProfileInfo{RealName: "Anthony Hopkins", FirstName: "Anthony", LastName: "Hopkins"}},
+		{Id: "U3", Name: "tlevine", Profile: &slack.ProfileInfo{RealName: "Ted Levine", FirstName: "Ted", LastName: "Levine"}},
+		{Id: "U4", Name: "tlevine2", Profile: &slack.ProfileInfo{RealName: "Ted Levin", FirstName: "Ted", LastName: "Levin"}},
+		{Id: "U5", Name: "renee", Profile: &slack.ProfileInfo{RealName: "Renée Zellweger"}},
+		{Id: "U6", Name: "sglenn", Profile: &slack.ProfileInfo{RealName: "Scott Glenn"}, Deleted: true},
+		nil,
+	}
+
+	noSkip := func(string) bool { return false }
+	r := newResolver(0, 0)
+
+	specs := []struct {
+		name   string
+		in     string
+		wantID string
+	}{
+		{"exact real name", "Jodie Foster", "U1"},
+		{"case insensitive", "jodie foster", "U1"},
+		{"phonetic spelling", "Jody Foster", "U1"},
+		{"first name", "anthony", "U2"},
+		{"last name", "hopkins", "U2"},
+		{"slack handle", "ahopkins", "U2"},
+		{"accent folded", "renee zellweger", "U5"},
+		{"deleted user", "Scott Glenn", ""},
+		{"no match", "Brooke Smith", ""},
+	}
+
+	for _, s := range specs {
+		t.Run(s.name, func(t *testing.T) {
+			got, err := r.resolveUser(users, s.in, noSkip)
+			if err != nil {
+				t.Fatal("expected no error but got", err)
+			}
+
+			var gotID string
+			if got != nil {
+				gotID = got.Id
+			}
+
+			if gotID != s.wantID {
+				t.Errorf("expected user %q but got %q", s.wantID, gotID)
+			}
+		})
+	}
+
+	t.Run("ambiguous match", func(t *testing.T) {
+		_, err := r.resolveUser(users, "ted", noSkip)
+		if err == nil {
+			t.Fatal("expected error but got none")
+		}
+
+		want := "I found more than one match for ted: Ted Levine or Ted Levin"
+		if err.Error() != want {
+			t.Fatalf("expected error %q but got %q", want, err)
+		}
+
+		if e := err.(ambiguousMatchError); len(e.targets) != 2 || e.targets[0].ID != "U3" || e.targets[1].ID != "U4" {
+			t.Errorf("expected targets U3 and U4 but got %v", e.targets)
+		}
+	})
+
+	t.Run("same named users", func(t *testing.T) {
+		users := []*slack.User{
+			{Id: "U1", Name: "abrown", Profile: &slack.ProfileInfo{RealName: "Alex Brown"}},
+			{Id: "U2", Name: "alexb", Profile: &slack.ProfileInfo{RealName: "Alex Brown"}},
+		}
+
+		_, err := r.resolveUser(users, "Alex Brown", noSkip)
+
+		want := "I found more than one match for Alex Brown: abrown or alexb"
+		if err == nil || err.Error() != want {
+			t.Fatalf("expected error %q but got %v", want, err)
+		}
+	})
+
+	t.Run("names only sounding alike", func(t *testing.T) {
+		users := []*slack.User{
+			{Id: "U1", Name: "ssmith", Profile: &slack.ProfileInfo{RealName: "Sean Smith", FirstName: "Sean", LastName: "Smith"}},
+		}
+
+		for _, r := range []resolver{r, newResolver(0.85, 0)} {
+			got, err := r.resolveUser(users, "Sam", noSkip)
+			if err != nil {
+				t.Fatal("expected no error but got", err)
+			}
+
+			if got != nil {
+				t.Errorf("expected no user at threshold %v but got %q", r.threshold, got.Id)
+			}
+		}
+	})
+
+	t.Run("closest match wins outside margin", func(t *testing.T) {
+		got, err := r.resolveUser(users, "Ted Levine", noSkip)
+		if err != nil {
+			t.Fatal("expected no error but got", err)
+		}
+
+		if got.Id != "U3" {
+			t.Errorf("expected user %q but got %q", "U3", got.Id)
+		}
+	})
+
+	t.Run("skipped users", func(t *testing.T) {
+		got, err := r.resolveUser(users, "Jodie Foster", func(id string) bool { return id == "U1" })
+		if err != nil {
+			t.Fatal("expected no error but got", err)
+		}
+
+		if got != nil {
+			t.Errorf("expected no user but got %q", got.Id)
+		}
+	})
+
+	t.Run("configured threshold", func(t *testing.T) {
+		r := newResolver(1, 0)
+
+		got, err := r.resolveUser(users, "Jody Foster", noSkip)
+		if err != nil {
+			t.Fatal("expected no error but got", err)
+		}
+
+		if got != nil {
+			t.Errorf("expected no user but got %q", got.Id)
+		}
+	})
+}
+
+func TestScoreNamePhonetic(t *testing.T) {
+	specs := []struct {
+		name, candidate string
+		match           bool
+	}{
+		{"Jody Foster", "Jodie Foster", true},
+		{"Jon", "John", true},
+		{"Sam", "Sean", false},
+		{"Ann", "Amy", false},
+		{"Tom", "Tim", false},
+		{"Jon", "Jen", false},
+		{"Ted", "Todd", false},
+	}
+
+	for _, s := range specs {
+		got := scoreName(s.name, s.candidate)
+		if match := got >= defaultMatchThreshold; match != s.match {
+			t.Errorf("expected %q matching %q to be %v but scored %.2f", s.name, s.candidate, s.match, got)
+		}
+	}
+}
+
+func TestResolveName(t *testing.T) {
+	names := []string{"Jodie Foster", "dev ops", "Jon Smith", "Jan Smith"}
+	r := newResolver(0, 0)
+
+	specs := []struct {
+		in      string
+		want    string
+		wantErr string
+	}{
+		{"jody foster", "Jodie Foster", ""},
+		{"devops", "dev ops", ""},
+		{"brooke smith", "", ""},
+		{"jen smith", "", "I found more than one match for jen smith: Jon Smith or Jan Smith"},
+	}
+
+	for _, s := range specs {
+		got, err := r.resolveName(names, s.in)
+
+		var gotErr string
+		if err != nil {
+			gotErr = err.Error()
+		}
+
+		if gotErr != s.wantErr {
+			t.Errorf("expected error %q but got %q", s.wantErr, gotErr)
+		}
+
+		if got != s.want {
+			t.Errorf("expected %q but got %q", s.want, got)
+		}
+	}
+}
+
+func TestSoundex(t *testing.T) {
+	specs := []struct {
+		in   string
+		want string
+	}{
+		{"robert", "R163"},
+		{"rupert", "R163"},
+		{"ashcraft", "A261"},
+		{"tymczak", "T522"},
+		{"pfister", "P236"},
+		{"jodie", "J300"},
+		{"jody", "J300"},
+		{"", ""},
+	}
+
+	for _, s := range specs {
+		if got := soundex(s.in); got != s.want {
+			t.Errorf("expected soundex of %q to be %q but got %q", s.in, s.want, got)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	specs := []struct {
+		in   string
+		want string
+	}{
+		{"Jodie Foster", "jodie foster"},
+		{"  José   Ñúñez ", "jose nunez"},
+		{"j.t", "j t"},
+		{"O'Brien", "o brien"},
+	}
+
+	for _, s := range specs {
+		if got := normalizeName(s.in); got != s.want {
+			t.Errorf("expected %q but got %q", s.want, got)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+const defaultSessionTimeout = 30 * time.Second
+
+var (
+	errSessionExpired = errors.New("Sorry, I waited too long for an answer, please ask again")
+	errCancelled      = errors.New("OK, I won't message anyone")
+)
+
+// pendingSession holds what a session is waiting on, either
+// pending messages to confirm or candidates to choose from
+type pendingSession struct {
+	route   model.IntentConfig
+	pending []pendingMessage
+
+	// name, spoken candidates and their
+	// targets of an ambiguous match
+	name       string
+	candidates []string
+	targets    []Target
+
+	expires time.Time
+}
+
+// sessionStore holds the pending sessions which have been
+// continued awaiting the user's answer, safe for concurrent use
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]pendingSession
+	timeout  time.Duration
+	now      func() time.Time
+}
+
+func newSessionStore(timeout time.Duration) *sessionStore {
+	if timeout == 0 {
+		timeout = defaultSessionTimeout
+	}
+
+	return &sessionStore{
+		sessions: map[string]pendingSession{},
+		timeout:  timeout,
+		now:      time.Now,
+	}
+}
+
+// put stores the pending session until it times
+// out and prunes any other expired sessions
+func (s *sessionStore) put(id string, ps pendingSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for k, v := range s.sessions {
+		if now.After(v.expires) {
+			delete(s.sessions, k)
+		}
+	}
+
+	ps.expires = now.Add(s.timeout)
+	s.sessions[id] = ps
+}
+
+// take removes and returns the pending session, reporting if it
+// was found and errSessionExpired when it has already timed out
+func (s *sessionStore) take(id string) (pendingSession, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ps, ok := s.sessions[id]
+	if !ok {
+		return ps, false, nil
+	}
+
+	delete(s.sessions, id)
+
+	if s.now().After(ps.expires) {
+		return ps, true, errSessionExpired
+	}
+
+	return ps, true, nil
+}
+
+// continueSession keeps the session open to ask the question of a
+// confirmation or ambiguous match error, reporting if it was asked
+func (mc mqttClient) continueSession(c mqtt.Client, p model.Payload, route model.IntentConfig, err error) bool {
+	sc := mc.config.SnipsConfig
+
+	var (
+		ps      = pendingSession{route: route}
+		text    string
+		intents []string
+	)
+
+	switch e := err.(type) {
+	case confirmationRequired:
+		if !sc.CanConfirm() {
+			return false
+		}
+
+		ps.pending = e.pending
+		text = e.question
+		intents = []string{sc.ConfirmIntent, sc.CancelIntent}
+	case ambiguousMatchError:
+		ps.name = e.name
+		ps.candidates = e.candidates
+		ps.targets = e.targets
+		text = fmt.Sprintf("Which %s, %s?", e.name, strings.Join(e.candidates, " or "))
+		intents = []string{route.Name}
+
+		if sc.ChoiceIntent != "" {
+			intents = append(intents, sc.ChoiceIntent)
+		}
+	default:
+		return false
+	}
+
+	if err := PublishContinueSession(c, mc.config.MQTTConfig.PubQoS(), p.SessionID, text, intents); err != nil {
+		log.Println("publish continue session error:", err)
+		return false
+	}
+
+	mc.sessions.put(p.SessionID, ps)
+	return true
+}
+
+// answerSession completes the pending session with the
+// user's answer and returns the text spoken back
+func (mc mqttClient) answerSession(p model.Payload, ps pendingSession) (string, error) {
+	if len(ps.candidates) > 0 {
+		return mc.answerChoice(p, ps)
+	}
+
+	if p.Intent.Name != mc.config.SnipsConfig.ConfirmIntent {
+		return "", errCancelled
+	}
+
+	var results []pingResult
+	for _, pm := range ps.pending {
+		r := pingResult{name: pm.target.Name, target: pm.target}
+		r.err = sendMessage(mc.notifier, pm.target, pm.msg)
+		if r.err != nil {
+			log.Printf("failed to message %q: %s\n", r.name, r.err)
+		}
+
+		results = append(results, r)
+	}
+
+	if ps.route.Action == model.ActionStandupMessage {
+		if results[0].err != nil {
+			return "", results[0].err
+		}
+
+		return "I've posted the standup message to " + ps.route.Channel, nil
+	}
+
+	return pingSummary(results), nil
+}
+
+// answerChoice pings the candidate best matching the
+// name given in the answer's slot without resolving it again
+func (mc mqttClient) answerChoice(p model.Payload, ps pendingSession) (string, error) {
+	slots := uniqueSlots(p, mc.config.SnipsConfig.SlotName)
+	if len(slots) == 0 {
+		return "", errCancelled
+	}
+
+	answer := slots[0].Value.Value
+
+	choice, err := newResolver(0, 0).resolveName(ps.candidates, answer)
+	if err != nil {
+		return "", err
+	}
+
+	i := indexOf(ps.candidates, choice)
+	if choice == "" || i >= len(ps.targets) {
+		return "", fmt.Errorf("%s wasn't one of %s", answer, strings.Join(ps.candidates, " or "))
+	}
+
+	r := mc.pingTarget(p, pingResult{name: choice, target: ps.targets[i]})
+	if r.err != nil {
+		return "", r.err
+	}
+
+	return "I've messaged " + choice, nil
+}
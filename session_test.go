@@ -0,0 +1,192 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSessionStore(t *testing.T) {
+	now := time.Date(2019, 1, 7, 9, 30, 0, 0, time.UTC)
+
+	s := newSessionStore(time.Minute)
+	s.now = func() time.Time { return now }
+
+	t.Run("default timeout", func(t *testing.T) {
+		if got := newSessionStore(0).timeout; got != defaultSessionTimeout {
+			t.Errorf("expected timeout %s but got %s", defaultSessionTimeout, got)
+		}
+	})
+
+	t.Run("take removes session", func(t *testing.T) {
+		s.put("123", pendingSession{name: "alex"})
+
+		ps, ok, err := s.take("123")
+		if !ok || err != nil {
+			t.Fatalf("expected session to be found but got %t %v", ok, err)
+		}
+
+		if ps.name != "alex" {
+			t.Errorf("expected session for %q but got %q", "alex", ps.name)
+		}
+
+		if _, ok, _ := s.take("123"); ok {
+			t.Error("expected session to be removed")
+		}
+	})
+
+	t.Run("expired session", func(t *testing.T) {
+		s.put("123", pendingSession{})
+		now = now.Add(2 * time.Minute)
+
+		_, ok, err := s.take("123")
+		if !ok || err != errSessionExpired {
+			t.Fatalf("expected expired session but got %t %v", ok, err)
+		}
+	})
+
+	t.Run("put prunes expired sessions", func(t *testing.T) {
+		s.put("old", pendingSession{})
+		now = now.Add(2 * time.Minute)
+		s.put("new", pendingSession{})
+
+		if len(s.sessions) != 1 {
+			t.Errorf("expected one session but got %d", len(s.sessions))
+		}
+	})
+}
+
+func TestDialogueContinuation(t *testing.T) {
+	slots := `"slots": [{"slotName": "slack_names", "confidence": 0.3, "value": {"value": "Alice"}}]`
+
+	buildClient := func(client testMQTTClient) mqttClient {
+		mc := buildTestClient(client)
+		mc.config.SnipsConfig.MinSlotConfidence = 0.5
+		mc.config.SnipsConfig.ConfirmIntent = "yes-intent"
+		mc.config.SnipsConfig.CancelIntent = "no-intent"
+		return mc
+	}
+
+	lastMessage := func(client testMQTTClient) string {
+		return string(client.token.messages[len(client.token.messages)-1].([]byte))
+	}
+
+	t.Run("confirmed", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildClient(client)
+
+		mc.MessageHandler(mc.client, testMessage{
+			payload: []byte(`{"sessionId": "123", "intent": {"intentName": "slack-intent"}, ` + slots + `}`),
+		})
+
+		want := `{"sessionId":"123","text":"Did you mean Alice?","intentFilter":["yes-intent","no-intent"]}`
+		if got := lastMessage(client); got != want {
+			t.Fatal(cmp.Diff(want, got))
+		}
+
+		if client.token.channel != "hermes/dialogueManager/continueSession" {
+			t.Fatalf("expected continue session but got %q", client.token.channel)
+		}
+
+		mc.MessageHandler(mc.client, testMessage{
+			payload: []byte(`{"sessionId": "123", "intent": {"intentName": "yes-intent"}}`),
+		})
+
+		want = `{"sessionId":"123","text":"I've messaged Alice"}`
+		if got := lastMessage(client); got != want {
+			t.Fatal(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildClient(client)
+
+		mc.MessageHandler(mc.client, testMessage{
+			payload: []byte(`{"sessionId": "123", "intent": {"intentName": "slack-intent"}, ` + slots + `}`),
+		})
+
+		mc.MessageHandler(mc.client, testMessage{
+			payload: []byte(`{"sessionId": "123", "intent": {"intentName": "no-intent"}}`),
+		})
+
+		want := `{"sessionId":"123","text":"OK, I won't message anyone"}`
+		if got := lastMessage(client); got != want {
+			t.Fatal(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("without confirm intents ends session", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildTestClient(client)
+		mc.config.SnipsConfig.MinSlotConfidence = 0.5
+
+		mc.MessageHandler(mc.client, testMessage{
+			payload: []byte(`{"sessionId": "123", "intent": {"intentName": "slack-intent"}, ` + slots + `}`),
+		})
+
+		want := `{"sessionId":"123","text":"Did you mean Alice?"}`
+		if got := lastMessage(client); got != want {
+			t.Fatal(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("ambiguous choice", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildClient(client)
+		mc.config.SnipsConfig.ChoiceIntent = "choice-intent"
+		var sent []Target
+		mc.notifier = failingNotifier{sent: &sent, resolveErrs: map[string]error{
+			"Alex": ambiguousMatchError{
+				name:       "Alex",
+				candidates: []string{"abrown", "abrown2", "Alex Green"},
+				targets: []Target{
+					{ID: "U1", Name: "Alex Brown", Kind: TargetUser},
+					{ID: "U2", Name: "Alex Brown", Kind: TargetUser},
+					{ID: "U3", Name: "Alex Green", Kind: TargetUser},
+				},
+			},
+		}}
+
+		mc.MessageHandler(mc.client, testMessage{
+			payload: []byte(`{"sessionId": "123", "intent": {"intentName": "slack-intent", "probability": 1},
+				"slots": [{"slotName": "slack_names", "confidence": 1, "value": {"value": "Alex"}}]}`),
+		})
+
+		want := `{"sessionId":"123","text":"Which Alex, abrown or abrown2 or Alex Green?","intentFilter":["slack-intent","choice-intent"]}`
+		if got := lastMessage(client); got != want {
+			t.Fatal(cmp.Diff(want, got))
+		}
+
+		mc.MessageHandler(mc.client, testMessage{
+			payload: []byte(`{"sessionId": "123", "intent": {"intentName": "choice-intent"},
+				"slots": [{"slotName": "slack_names", "value": {"value": "abrown2"}}]}`),
+		})
+
+		want = `{"sessionId":"123","text":"I've messaged abrown2"}`
+		if got := lastMessage(client); got != want {
+			t.Fatal(cmp.Diff(want, got))
+		}
+
+		if len(sent) != 1 || sent[0].ID != "U2" {
+			t.Errorf("expected the chosen target U2 to be messaged but got %v", sent)
+		}
+	})
+
+	t.Run("expired answer", func(t *testing.T) {
+		client := testMQTTClient{token: &testToken{}}
+		mc := buildClient(client)
+		mc.sessions.put("123", pendingSession{})
+		mc.sessions.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+		mc.MessageHandler(mc.client, testMessage{
+			payload: []byte(`{"sessionId": "123", "intent": {"intentName": "yes-intent"}}`),
+		})
+
+		want := `{"sessionId":"123","text":"Sorry, I waited too long for an answer, please ask again"}`
+		if got := lastMessage(client); got != want {
+			t.Fatal(cmp.Diff(want, got))
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bluele/slack"
+)
+
+// slackAPIURL is the base URL of the slack web API
+var slackAPIURL = "https://slack.com/api/"
+
+var (
+	// Each slack call is attempted up to slackAttempts times
+	// within its timeout, waiting as long as slack asks when
+	// rate limited otherwise backing off from slackBackoff
+	slackAttempts   = 3
+	slackBackoff    = time.Second
+	slackMaxBackoff = 10 * time.Second
+
+	// Refreshing the directory runs in the background so it waits
+	// longer than calls answering a message, which block the other
+	// messages and must reply before the snips session ends
+	slackTimeout        = 30 * time.Second
+	slackMessageTimeout = 5 * time.Second
+
+	// slackWait waits d or until ctx is done
+	slackWait = func(ctx context.Context, d time.Duration) error {
+		select {
+		case <-time.After(d):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+)
+
+// slackErrorKind classifies why a slack call failed
+type slackErrorKind int
+
+const (
+	slackErrUnknown slackErrorKind = iota
+	slackErrAuth
+	slackErrNotInChannel
+	slackErrRateLimited
+	slackErrNetwork
+)
+
+// slackErrorKinds maps the error codes slack returns to their kind
+var slackErrorKinds = map[string]slackErrorKind{
+	"invalid_auth":      slackErrAuth,
+	"not_authed":        slackErrAuth,
+	"account_inactive":  slackErrAuth,
+	"token_revoked":     slackErrAuth,
+	"token_expired":     slackErrAuth,
+	"missing_scope":     slackErrAuth,
+	"not_in_channel":    slackErrNotInChannel,
+	"channel_not_found": slackErrNotInChannel,
+	"is_archived":       slackErrNotInChannel,
+	"ratelimited":       slackErrRateLimited,
+}
+
+// slackError is a failed slack call which is spoken back
+// to the user, the underlying error is only logged
+type slackError struct {
+	kind slackErrorKind
+	err  error
+
+	// retryAfter is how long slack asked us to wait
+	retryAfter time.Duration
+}
+
+func (e slackError) Error() string {
+	switch e.kind {
+	case slackErrAuth:
+		return "I'm not allowed to do that on slack, check my token"
+	case slackErrNotInChannel:
+		return "I'm not in that slack channel, invite me first"
+	case slackErrRateLimited:
+		return "Slack is busy, please try again in a minute"
+	case slackErrNetwork:
+		return "I couldn't reach slack"
+	}
+
+	return fmt.Sprintf("slack failed with %s", e.err)
+}
+
+// retryable reports whether the call may be attempted again,
+// only rate limited calls weren't processed so others are
+// only retried when repeating them is safe
+func (e slackError) retryable(idempotent bool) bool {
+	switch e.kind {
+	case slackErrRateLimited:
+		return true
+	case slackErrNetwork:
+		return idempotent
+	}
+
+	return false
+}
+
+// slackCodeError returns the error for the code slack returned
+func slackCodeError(code string) slackError {
+	return slackError{kind: slackErrorKinds[code], err: errors.New(code)}
+}
+
+// slackGet calls the slack API method with the query uv
+// within timeout returning the body of a successful response
+func slackGet(method, token string, uv url.Values, timeout time.Duration) ([]byte, error) {
+	return slackCall(method, token, true, timeout, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, slackAPIURL+method+"?"+uv.Encode(), nil)
+	})
+}
+
+// slackPost posts uv as a form to the slack API method
+// within timeout returning the body of a successful response
+func slackPost(method, token string, uv url.Values, timeout time.Duration) ([]byte, error) {
+	return slackCall(method, token, false, timeout, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, slackAPIURL+method, strings.NewReader(uv.Encode()))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
+}
+
+// slackCall sends the request built by newReq authorized by the
+// token, kept out of the URL, retrying failures bounded by
+// slackAttempts and timeout
+func slackCall(method, token string, idempotent bool, timeout time.Duration, newReq func() (*http.Request, error)) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	b := newBackoff(slackBackoff, slackMaxBackoff)
+
+	for attempt := 1; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		body, err := slackDo(req.WithContext(ctx))
+		if err == nil {
+			return body, nil
+		}
+
+		se := err.(slackError)
+		if attempt >= slackAttempts || !se.retryable(idempotent) {
+			log.Printf("slack %s failed: %s\n", method, se.err)
+			return nil, se
+		}
+
+		delay := se.retryAfter
+		if delay == 0 {
+			delay = b.next()
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			log.Printf("slack %s failed, no time left to retry in %s: %s\n", method, delay, se.err)
+			return nil, se
+		}
+
+		log.Printf("slack %s failed, retrying in %s: %s\n", method, delay, se.err)
+		if err := slackWait(ctx, delay); err != nil {
+			return nil, se
+		}
+	}
+}
+
+// slackDo sends the request returning a slackError classifying
+// any failure or the body when slack reports it ok
+func slackDo(req *http.Request) ([]byte, error) {
+	res, err := httpClient.Do(req)
+	if err != nil {
+		// The url is left out as it may hold secrets
+		if ue, ok := err.(*url.Error); ok {
+			err = fmt.Errorf("%s: %s", ue.Op, ue.Err)
+		}
+
+		return nil, slackError{kind: slackErrNetwork, err: err}
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		return nil, slackError{
+			kind:       slackErrRateLimited,
+			err:        errors.New(res.Status),
+			retryAfter: retryAfter(res.Header.Get("Retry-After")),
+		}
+	case res.StatusCode >= 500:
+		return nil, slackError{kind: slackErrNetwork, err: errors.New(res.Status)}
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, slackError{kind: slackErrNetwork, err: err}
+	}
+
+	var base slack.BaseAPIResponse
+	if err := json.Unmarshal(b, &base); err != nil {
+		return nil, slackError{err: err}
+	}
+
+	if !base.Ok {
+		return nil, slackCodeError(base.Error)
+	}
+
+	return b, nil
+}
+
+// retryAfter parses the seconds of a Retry-After header
+func retryAfter(v string) time.Duration {
+	s, err := strconv.Atoi(v)
+	if err != nil || s < 0 {
+		return 0
+	}
+
+	return time.Duration(s) * time.Second
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+// isSlackError reports whether err is a slackError of the kind
+func isSlackError(err error, kind slackErrorKind) bool {
+	se, ok := err.(slackError)
+	return ok && se.kind == kind
+}
+
+// recordSlackWaits replaces slackWait recording each delay
+// instead of waiting until the returned func is called
+func recordSlackWaits() (*[]time.Duration, func()) {
+	var waits []time.Duration
+
+	wait := slackWait
+	slackWait = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	return &waits, func() { slackWait = wait }
+}
+
+func TestSlackErrors(t *testing.T) {
+	specs := map[string]struct {
+		kind slackErrorKind
+		want string
+	}{
+		"invalid_auth":      {slackErrAuth, "I'm not allowed to do that on slack, check my token"},
+		"missing_scope":     {slackErrAuth, "I'm not allowed to do that on slack, check my token"},
+		"not_in_channel":    {slackErrNotInChannel, "I'm not in that slack channel, invite me first"},
+		"channel_not_found": {slackErrNotInChannel, "I'm not in that slack channel, invite me first"},
+		"ratelimited":       {slackErrRateLimited, "Slack is busy, please try again in a minute"},
+		"invalid_blocks":    {slackErrUnknown, "slack failed with invalid_blocks"},
+	}
+
+	for code, s := range specs {
+		err := slackCodeError(code)
+		if err.kind != s.kind || err.Error() != s.want {
+			t.Errorf("expected %d %q for %s but got %d %q", s.kind, s.want, code, err.kind, err.Error())
+		}
+	}
+
+	if got := (slackError{kind: slackErrNetwork}).Error(); got != "I couldn't reach slack" {
+		t.Errorf("expected network error but got %q", got)
+	}
+}
+
+func TestSlackGetRetries(t *testing.T) {
+	f, done := newFakeSlack(map[string][]string{"users.list": {`"members": []`}})
+	defer done()
+
+	waits, restore := recordSlackWaits()
+	defer restore()
+
+	f.statuses["users.list"] = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	f.retryAfter = "7"
+
+	if _, err := slackGet("users.list", "xoxb-1234", url.Values{}, slackTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(f.requests["users.list"]); got != 3 {
+		t.Errorf("expected 3 requests but got %d", got)
+	}
+
+	if len(*waits) != 2 || (*waits)[0] < slackBackoff/2 || (*waits)[0] > slackBackoff {
+		t.Fatalf("expected a backoff then the retry after but got %v", *waits)
+	}
+
+	if (*waits)[1] != 7*time.Second {
+		t.Errorf("expected to wait 7s as slack asked but got %s", (*waits)[1])
+	}
+}
+
+func TestSlackPostRetries(t *testing.T) {
+	f, done := newFakeSlack(map[string][]string{"chat.postMessage": {`"ts": "1"`}})
+	defer done()
+
+	_, restore := recordSlackWaits()
+	defer restore()
+
+	f.statuses["chat.postMessage"] = []int{http.StatusBadGateway}
+	if _, err := slackPost("chat.postMessage", "xoxb-1234", url.Values{}, slackMessageTimeout); !isSlackError(err, slackErrNetwork) {
+		t.Errorf("expected a network error but got %v", err)
+	}
+
+	if got := len(f.requests["chat.postMessage"]); got != 1 {
+		t.Errorf("expected a failed post not to be retried but got %d requests", got)
+	}
+
+	f.statuses["chat.postMessage"] = []int{http.StatusTooManyRequests}
+	if _, err := slackPost("chat.postMessage", "xoxb-1234", url.Values{}, slackMessageTimeout); err != nil {
+		t.Errorf("expected a rate limited post to be retried but got %v", err)
+	}
+
+	if got := len(f.requests["chat.postMessage"]); got != 3 {
+		t.Errorf("expected 3 requests but got %d", got)
+	}
+}
+
+func TestSlackCallGivesUp(t *testing.T) {
+	f, done := newFakeSlack(map[string][]string{"users.list": {`"members": []`}})
+	defer done()
+
+	waits, restore := recordSlackWaits()
+	defer restore()
+
+	t.Run("after the attempts", func(t *testing.T) {
+		f.statuses["users.list"] = []int{500, 500, 500, 500, 500}
+
+		if _, err := slackGet("users.list", "xoxb-1234", url.Values{}, slackTimeout); !isSlackError(err, slackErrNetwork) {
+			t.Errorf("expected a network error but got %v", err)
+		}
+
+		if got := len(f.requests["users.list"]); got != slackAttempts {
+			t.Errorf("expected %d requests but got %d", slackAttempts, got)
+		}
+	})
+
+	t.Run("when slack asks to wait past the deadline", func(t *testing.T) {
+		*waits = nil
+		f.statuses["users.list"] = []int{http.StatusTooManyRequests}
+		f.retryAfter = "60"
+
+		if _, err := slackGet("users.list", "xoxb-1234", url.Values{}, slackTimeout); !isSlackError(err, slackErrRateLimited) {
+			t.Errorf("expected a rate limited error but got %v", err)
+		}
+
+		if len(*waits) != 0 {
+			t.Errorf("expected no wait but got %v", *waits)
+		}
+	})
+
+	t.Run("when slack asks to wait past a message's deadline", func(t *testing.T) {
+		*waits = nil
+		f.statuses["users.list"] = []int{http.StatusTooManyRequests}
+		f.statuses["chat.postMessage"] = []int{http.StatusTooManyRequests}
+		f.retryAfter = "10"
+
+		if _, err := slackGet("users.list", "xoxb-1234", url.Values{}, slackTimeout); err != nil {
+			t.Fatal(err)
+		}
+
+		if want := []time.Duration{10 * time.Second}; !cmp.Equal(want, *waits) {
+			t.Fatalf("expected refreshing to wait %v but got %v", want, *waits)
+		}
+
+		*waits = nil
+		n := slackNotifier{config: model.SlackConfig{Token: "xoxb-1234"}}
+		if err := n.Send(Target{ID: "U1", Kind: TargetUser}, "standup!"); !isSlackError(err, slackErrRateLimited) {
+			t.Errorf("expected a rate limited error but got %v", err)
+		}
+
+		if len(*waits) != 0 {
+			t.Errorf("expected no wait but got %v", *waits)
+		}
+	})
+
+	t.Run("when slack can't be reached", func(t *testing.T) {
+		f.Close()
+
+		if _, err := slackGet("users.list", "xoxb-1234", url.Values{}, slackTimeout); !isSlackError(err, slackErrNetwork) {
+			t.Errorf("expected a network error but got %v", err)
+		}
+	})
+}
+
+func TestSlackCallLogsNoSecrets(t *testing.T) {
+	f, done := newFakeSlack(map[string][]string{})
+	defer done()
+
+	_, restore := recordSlackWaits()
+	defer restore()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	f.Close()
+
+	uv := url.Values{"cursor": {"page-secret"}}
+	if _, err := slackGet("users.list", "xoxb-secret", uv, slackTimeout); !isSlackError(err, slackErrNetwork) {
+		t.Fatalf("expected a network error but got %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "slack users.list failed") {
+		t.Fatalf("expected the failure logged but got %q", buf.String())
+	}
+
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("expected no secrets logged but got %q", buf.String())
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	specs := map[string]time.Duration{
+		"":     0,
+		"3":    3 * time.Second,
+		"-1":   0,
+		"soon": 0,
+	}
+
+	got := map[string]time.Duration{}
+	for in := range specs {
+		got[in] = retryAfter(in)
+	}
+
+	if !cmp.Equal(specs, got) {
+		t.Error(cmp.Diff(specs, got))
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"strconv"
+
+	"github.com/bluele/slack"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+const defaultSlackPageLimit = 200
+
+// defaultConversationTypes are the conversations loaded
+// when no types are configured, DMs have no name to speak
+var defaultConversationTypes = []string{"public_channel", "private_channel", "mpim"}
+
+// slackPage is the part of every paginated
+// slack response used to fetch the next page
+type slackPage struct {
+	Metadata struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+}
+
+// slackConversation is a channel, private channel
+// or group DM returned by conversations.list
+type slackConversation struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	IsArchived bool     `json:"is_archived"`
+	IsMpim     bool     `json:"is_mpim"`
+	Members    []string `json:"members"`
+}
+
+// slackMember is the profile of a users.list member decoded
+// apart from the vendored user for display_name and custom
+// fields, which are keyed by their ID i.e "Xf0123ABCD"
+type slackMember struct {
+	ID      string `json:"id"`
+	Profile struct {
+		DisplayName string `json:"display_name"`
+		Fields      map[string]struct {
+			Value string `json:"value"`
+		} `json:"fields"`
+	} `json:"profile"`
+}
+
+// field returns the value of the profile field by its json name
+func (m slackMember) field(name string) string {
+	if name == "display_name" {
+		return m.Profile.DisplayName
+	}
+
+	return m.Profile.Fields[name].Value
+}
+
+// slackLoader loads the slack directory following
+// the cursor of each method until every page is read
+type slackLoader struct {
+	token string
+	limit int
+	types []string
+
+	// profileFields are the profile fields kept
+	// for each user besides the vendored profile
+	profileFields []string
+}
+
+func newSlackLoader(c model.SlackConfig) slackLoader {
+	l := slackLoader{token: c.Token, limit: c.PageLimit, types: c.ConversationTypes}
+	if c.AliasProfileField != "" {
+		l.profileFields = []string{c.AliasProfileField}
+	}
+
+	if l.limit == 0 {
+		l.limit = defaultSlackPageLimit
+	}
+
+	if len(l.types) == 0 {
+		l.types = defaultConversationTypes
+	}
+
+	return l
+}
+
+// Users returns every user in the workspace
+// with the profile fields kept for each of them
+func (l slackLoader) Users() ([]*slack.User, model.Profiles, error) {
+	var users []*slack.User
+	profiles := model.Profiles{}
+
+	err := l.paginate("users.list", url.Values{}, func(b []byte) (int, error) {
+		var res struct {
+			Members []*slack.User `json:"members"`
+		}
+
+		if err := json.Unmarshal(b, &res); err != nil {
+			return 0, err
+		}
+
+		var members struct {
+			Members []slackMember `json:"members"`
+		}
+
+		if err := json.Unmarshal(b, &members); err != nil {
+			return 0, err
+		}
+
+		for _, m := range members.Members {
+			for _, f := range l.profileFields {
+				if v := m.field(f); v != "" {
+					if profiles[m.ID] == nil {
+						profiles[m.ID] = map[string]string{}
+					}
+
+					profiles[m.ID][f] = v
+				}
+			}
+		}
+
+		users = append(users, res.Members...)
+		return len(users), nil
+	})
+
+	return users, profiles, err
+}
+
+// Conversations returns the unarchived conversations of the configured
+// types as channels, group DMs are named after their members in dir.
+// Each type is loaded separately as private channels and group DMs
+// need extra scopes, so they're skipped when failing unless public
+// channels or every type failed.
+func (l slackLoader) Conversations(dir *directory) ([]*slack.Channel, error) {
+	var (
+		chls   []*slack.Channel
+		failed int
+	)
+
+	for _, typ := range l.types {
+		c, err := l.conversations(typ, dir)
+		if err == nil {
+			chls = append(chls, c...)
+			continue
+		}
+
+		if typ == "public_channel" {
+			return nil, err
+		}
+
+		log.Printf("get slack %s conversations failed %s\n", typ, err)
+		if failed++; failed == len(l.types) {
+			return nil, err
+		}
+	}
+
+	return chls, nil
+}
+
+// conversations returns the unarchived conversations of the type
+func (l slackLoader) conversations(typ string, dir *directory) ([]*slack.Channel, error) {
+	var chls []*slack.Channel
+
+	uv := url.Values{}
+	uv.Add("types", typ)
+	uv.Add("exclude_archived", "true")
+
+	err := l.paginate("conversations.list", uv, func(b []byte) (int, error) {
+		var res struct {
+			Channels []slackConversation `json:"channels"`
+		}
+
+		if err := json.Unmarshal(b, &res); err != nil {
+			return 0, err
+		}
+
+		for _, c := range res.Channels {
+			name := c.Name
+			if c.IsMpim {
+				name = mpimName(name, dir)
+			}
+
+			chls = append(chls, &slack.Channel{Id: c.ID, Name: name, IsArchived: c.IsArchived, Members: c.Members})
+		}
+
+		return len(chls), nil
+	})
+
+	return chls, err
+}
+
+// paginate calls the method passing each page's body to fn, which
+// returns the total loaded so far, until no next cursor is returned
+func (l slackLoader) paginate(method string, uv url.Values, fn func([]byte) (int, error)) error {
+	uv.Set("limit", strconv.Itoa(l.limit))
+
+	for page := 1; ; page++ {
+		b, err := slackGet(method, l.token, uv, slackTimeout)
+		if err != nil {
+			return err
+		}
+
+		var p slackPage
+		if err := json.Unmarshal(b, &p); err != nil {
+			return err
+		}
+
+		total, err := fn(b)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("%s page %d loaded, %d so far\n", method, page, total)
+
+		if p.Metadata.NextCursor == "" {
+			return nil
+		}
+
+		uv.Set("cursor", p.Metadata.NextCursor)
+	}
+}
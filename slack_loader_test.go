@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bluele/slack"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+// fakeSlack serves slack API methods a page at a time from canned
+// json fields keyed by method, recording the query and Authorization
+// header of each request.
+// Conversations are keyed by type too, i.e conversations.list?types=mpim.
+// Each method first fails with any queued statuses, 429s asking
+// to retry after retryAfter.
+type fakeSlack struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	pages      map[string][]string
+	errors     map[string]string
+	statuses   map[string][]int
+	retryAfter string
+	requests   map[string][]url.Values
+	auths      map[string][]string
+}
+
+// newFakeSlack starts the fake pointing slackAPIURL
+// at it until the returned func is called
+func newFakeSlack(pages map[string][]string) (*fakeSlack, func()) {
+	f := &fakeSlack{
+		pages:    pages,
+		errors:   map[string]string{},
+		statuses: map[string][]int{},
+		requests: map[string][]url.Values{},
+		auths:    map[string][]string{},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+
+	apiURL := slackAPIURL
+	slackAPIURL = f.URL + "/api/"
+
+	return f, func() {
+		slackAPIURL = apiURL
+		f.Close()
+	}
+}
+
+func (f *fakeSlack) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r.ParseForm()
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	f.requests[method] = append(f.requests[method], r.Form)
+	f.auths[method] = append(f.auths[method], r.Header.Get("Authorization"))
+
+	if s := f.statuses[method]; len(s) > 0 {
+		f.statuses[method] = s[1:]
+		if s[0] == http.StatusTooManyRequests && f.retryAfter != "" {
+			w.Header().Set("Retry-After", f.retryAfter)
+		}
+
+		w.WriteHeader(s[0])
+		return
+	}
+
+	key := method
+	if t := r.Form.Get("types"); t != "" {
+		key += "?types=" + t
+	}
+
+	if e, ok := f.errors[key]; ok {
+		fmt.Fprintf(w, `{"ok": false, "error": %q}`, e)
+		return
+	}
+
+	page := 0
+	if c := r.Form.Get("cursor"); c != "" {
+		page, _ = strconv.Atoi(strings.TrimPrefix(c, "page-"))
+	}
+
+	pages := f.pages[key]
+	if page >= len(pages) {
+		fmt.Fprint(w, `{"ok": false, "error": "invalid_cursor"}`)
+		return
+	}
+
+	var next string
+	if page+1 < len(pages) {
+		next = fmt.Sprintf("page-%d", page+1)
+	}
+
+	fmt.Fprintf(w, `{"ok": true, %s, "response_metadata": {"next_cursor": %q}}`, pages[page], next)
+}
+
+func TestNewSlackLoader(t *testing.T) {
+	l := newSlackLoader(model.SlackConfig{Token: "xoxb-1234"})
+	want := slackLoader{token: "xoxb-1234", limit: defaultSlackPageLimit, types: defaultConversationTypes}
+	if !reflect.DeepEqual(want, l) {
+		t.Errorf("expected loader %+v but got %+v", want, l)
+	}
+
+	l = newSlackLoader(model.SlackConfig{PageLimit: 50, ConversationTypes: []string{"public_channel"}, AliasProfileField: "display_name"})
+	if l.limit != 50 || !cmp.Equal(l.types, []string{"public_channel"}) || !cmp.Equal(l.profileFields, []string{"display_name"}) {
+		t.Errorf("expected configured limit, types and profile fields but got %d %v %v", l.limit, l.types, l.profileFields)
+	}
+}
+
+func TestSlackLoaderUsers(t *testing.T) {
+	f, done := newFakeSlack(map[string][]string{
+		"users.list": {
+			`"members": [{"id": "U1", "name": "jfoster", "profile": {"display_name": "Jo"}}, {"id": "U2", "name": "ahopkins"}]`,
+			`"members": [{"id": "U3", "name": "tlevine", "profile": {"fields": {"Xf01": {"value": "Teddy"}}}}]`,
+			`"members": [{"id": "U4", "name": "sglenn", "deleted": true}]`,
+		},
+	})
+	defer done()
+
+	l := slackLoader{token: "xoxb-1234", limit: 2, profileFields: []string{"display_name", "Xf01"}}
+	users, profiles, err := l.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantProfiles := model.Profiles{"U1": {"display_name": "Jo"}, "U3": {"Xf01": "Teddy"}}
+	if !cmp.Equal(wantProfiles, profiles) {
+		t.Error(cmp.Diff(wantProfiles, profiles))
+	}
+
+	var got []string
+	for _, u := range users {
+		got = append(got, u.Id)
+	}
+
+	if want := []string{"U1", "U2", "U3", "U4"}; !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+
+	var cursors []string
+	for i, q := range f.requests["users.list"] {
+		if q.Get("token") != "" || q.Get("limit") != "2" {
+			t.Errorf("expected only the limit in query but got %v", q)
+		}
+
+		if a := f.auths["users.list"][i]; a != "Bearer xoxb-1234" {
+			t.Errorf("expected the token in the Authorization header but got %q", a)
+		}
+
+		cursors = append(cursors, q.Get("cursor"))
+	}
+
+	if want := []string{"", "page-1", "page-2"}; !cmp.Equal(want, cursors) {
+		t.Error(cmp.Diff(want, cursors))
+	}
+}
+
+func TestSlackLoaderConversations(t *testing.T) {
+	f, done := newFakeSlack(map[string][]string{
+		"conversations.list?types=public_channel": {
+			`"channels": [{"id": "C1", "name": "devops"}]`,
+			`"channels": [{"id": "C2", "name": "general"}]`,
+		},
+		"conversations.list?types=private_channel": {`"channels": [{"id": "G1", "name": "team-private", "is_private": true}]`},
+		"conversations.list?types=mpim":            {`"channels": [{"id": "G2", "name": "mpdm-jfoster--tlevine-1", "is_mpim": true}]`},
+	})
+	defer done()
+
+	dir := newDirectory()
+	dir.SetUsers([]*slack.User{{Id: "U1", Name: "jfoster", Profile: &slack.ProfileInfo{FirstName: "Jodie"}}})
+
+	l := slackLoader{token: "xoxb-1234", limit: 1, types: defaultConversationTypes}
+	got, err := l.Conversations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*slack.Channel{
+		{Id: "C1", Name: "devops"},
+		{Id: "C2", Name: "general"},
+		{Id: "G1", Name: "team-private"},
+		{Id: "G2", Name: "Jodie and tlevine"},
+	}
+
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+
+	var types []string
+	for _, q := range f.requests["conversations.list"] {
+		if q.Get("exclude_archived") != "true" {
+			t.Errorf("expected exclude archived in query but got %v", q)
+		}
+
+		types = append(types, q.Get("types"))
+	}
+
+	if want := []string{"public_channel", "public_channel", "private_channel", "mpim"}; !cmp.Equal(want, types) {
+		t.Error(cmp.Diff(want, types))
+	}
+
+	t.Run("missing scopes skip private types", func(t *testing.T) {
+		f.errors["conversations.list?types=private_channel"] = "missing_scope"
+		f.errors["conversations.list?types=mpim"] = "missing_scope"
+		defer delete(f.errors, "conversations.list?types=private_channel")
+		defer delete(f.errors, "conversations.list?types=mpim")
+
+		got, err := l.Conversations(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := want[:2]; !cmp.Equal(want, got) {
+			t.Error(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("only private types failing", func(t *testing.T) {
+		f.errors["conversations.list?types=mpim"] = "missing_scope"
+		defer delete(f.errors, "conversations.list?types=mpim")
+
+		l := slackLoader{token: "xoxb-1234", limit: 1, types: []string{"mpim"}}
+		if _, err := l.Conversations(dir); !isSlackError(err, slackErrAuth) {
+			t.Errorf("expected missing_scope error but got %v", err)
+		}
+	})
+}
+
+func TestSlackLoaderErrors(t *testing.T) {
+	f, done := newFakeSlack(map[string][]string{
+		"users.list": {`"members": [{"id": "U1"}]`},
+	})
+	defer done()
+
+	f.errors["conversations.list?types=public_channel"] = "missing_scope"
+
+	l := slackLoader{token: "xoxb-1234", limit: 1, types: defaultConversationTypes}
+	if _, err := l.Conversations(newDirectory()); !isSlackError(err, slackErrAuth) {
+		t.Errorf("expected missing_scope error but got %v", err)
+	}
+
+	f.pages["users.list"] = append(f.pages["users.list"], `"members": {}`)
+	if _, _, err := l.Users(); err == nil {
+		t.Error("expected an error decoding the second page")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+// slackUsergroup is a slack user group such as @backend,
+// pinged by mentioning it in one of its default channels
+type slackUsergroup struct {
+	ID     string `json:"id"`
+	Handle string `json:"handle"`
+	Name   string `json:"name"`
+	Prefs  struct {
+		Channels []string `json:"channels"`
+	} `json:"prefs"`
+}
+
+// Mention returns the syntax notifying the group's members
+func (g slackUsergroup) Mention() string {
+	return "<!subteam^" + g.ID + ">"
+}
+
+// usergroupsResponse is the response of usergroups.list
+type usergroupsResponse struct {
+	Usergroups []slackUsergroup `json:"usergroups"`
+}
+
+// noUsergroupChannelError is returned when a user group has
+// no default channels and no usergroup channel is configured
+type noUsergroupChannelError struct {
+	name string
+}
+
+func (e noUsergroupChannelError) Error() string {
+	return fmt.Sprintf("I don't know which channel to mention %s in", e.name)
+}
+
+// listUsergroups returns the enabled user groups of the workspace
+func listUsergroups(token string) ([]slackUsergroup, error) {
+	b, err := slackGet("usergroups.list", token, url.Values{}, slackTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var res usergroupsResponse
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Usergroups, nil
+}
+
+// mpimName turns the generated name of a group DM such as
+// mpdm-alice--bob--carol-1 into its members' first names
+func mpimName(name string, dir *directory) string {
+	name = strings.TrimPrefix(name, "mpdm-")
+	if i := strings.LastIndex(name, "-"); i > 0 {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			name = name[:i]
+		}
+	}
+
+	var names []string
+	for _, h := range strings.Split(name, "--") {
+		n := h
+		if u, ok := dir.UserByHandle(h); ok && u.Profile != nil {
+			n = firstNonEmpty(u.Profile.FirstName, u.Profile.RealName, h)
+		}
+
+		names = append(names, n)
+	}
+
+	return joinNames(names)
+}
+
+// usergroupTarget returns the target posting to the group's first
+// known default channel or the configured usergroup channel
+func (n slackNotifier) usergroupTarget(g slackUsergroup) (Target, error) {
+	t := Target{Name: g.Name, Kind: TargetUsergroup, Mention: g.Mention()}
+
+	for _, id := range g.Prefs.Channels {
+		if _, ok := n.dir.Channel(id); ok && !n.config.IsBlacklisted(id) {
+			t.ID = id
+			return t, nil
+		}
+	}
+
+	if n.config.UsergroupChannel != "" {
+		if c, ok := n.resolveChannel(n.config.UsergroupChannel); ok {
+			t.ID = c.Id
+			return t, nil
+		}
+	}
+
+	return Target{}, noUsergroupChannelError{name: g.Name}
+}
+
+// entityValues returns the values injected for the directory,
+// user groups are injected into the channel slot
+func entityValues(conf model.Config, dir *directory) map[string][]string {
+	values := model.EntityValues(conf, dir.Users(), dir.Profiles(), dir.Channels())
+
+	slot := conf.SnipsConfig.ChannelSlot()
+	aliases := conf.SlackConfig.ChannelAliases
+	for _, g := range dir.Usergroups() {
+		values[slot] = append(values[slot], model.ChannelSpokenNames(g.Name, aliases)...)
+		if !strings.EqualFold(g.Handle, g.Name) {
+			values[slot] = append(values[slot], model.ChannelSpokenNames(g.Handle, aliases)...)
+		}
+	}
+
+	return values
+}
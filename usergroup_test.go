@@ -0,0 +1,192 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluele/slack"
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+func testUsergroup(id, handle, name string, channels ...string) slackUsergroup {
+	g := slackUsergroup{ID: id, Handle: handle, Name: name}
+	g.Prefs.Channels = channels
+	return g
+}
+
+func TestListUsergroups(t *testing.T) {
+	f, done := newFakeSlack(map[string][]string{
+		"usergroups.list": {
+			`"usergroups": [{"id": "S1", "handle": "backend", "name": "Backend Team", "prefs": {"channels": ["C1"], "groups": []}}]`,
+		},
+	})
+	defer done()
+
+	got, err := listUsergroups("xoxb-1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a := f.auths["usergroups.list"][0]; a != "Bearer xoxb-1234" {
+		t.Errorf("expected the token in the Authorization header but got %q", a)
+	}
+
+	want := []slackUsergroup{testUsergroup("S1", "backend", "Backend Team", "C1")}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+
+	f.errors["usergroups.list"] = "missing_scope"
+	if _, err := listUsergroups("xoxb-1234"); !isSlackError(err, slackErrAuth) {
+		t.Errorf("expected missing_scope error but got %v", err)
+	}
+}
+
+func TestMpimName(t *testing.T) {
+	dir := newDirectory()
+	dir.SetUsers([]*slack.User{
+		{Id: "U1", Name: "jfoster", Profile: &slack.ProfileInfo{FirstName: "Jodie", RealName: "Jodie Foster"}},
+		{Id: "U2", Name: "tlevine", Profile: &slack.ProfileInfo{RealName: "Ted Levine"}},
+	})
+
+	specs := map[string]string{
+		"mpdm-jfoster--tlevine--sglenn-1": "Jodie, Ted Levine and sglenn",
+		"mpdm-jfoster--tlevine-12":        "Jodie and Ted Levine",
+		"mpdm-jfoster--tlevine":           "Jodie and Ted Levine",
+	}
+
+	for in, want := range specs {
+		if got := mpimName(in, dir); got != want {
+			t.Errorf("expected %q for %q but got %q", want, in, got)
+		}
+	}
+}
+
+func TestSlackNotifierResolveUsergroup(t *testing.T) {
+	dir := newDirectory()
+	dir.SetChannels([]*slack.Channel{
+		{Id: "C1", Name: "backend"},
+		{Id: "C2", Name: "general"},
+		{Id: "G1", Name: "team-private"},
+	})
+	dir.SetUsergroups([]slackUsergroup{
+		testUsergroup("S1", "backend-devs", "Backend Team", "C9", "C1"),
+		testUsergroup("S2", "frontend", "Frontend"),
+		testUsergroup("S3", "mobile", "Mobile"),
+	})
+
+	n := slackNotifier{
+		config: model.SlackConfig{
+			Blacklist:        []string{"S3"},
+			ChannelAliases:   map[string][]string{"frontend": {"web team"}},
+			UsergroupChannel: "team private",
+		},
+		dir: dir,
+	}
+
+	specs := []struct {
+		in      string
+		kind    TargetKind
+		want    Target
+		wantErr string
+	}{
+		{"backend team", TargetAny, Target{ID: "C1", Name: "Backend Team", Kind: TargetUsergroup, Mention: "<!subteam^S1>"}, ""},
+		{"backend devs", TargetChannel, Target{ID: "C1", Name: "Backend Team", Kind: TargetUsergroup, Mention: "<!subteam^S1>"}, ""},
+		{"web team", TargetAny, Target{ID: "G1", Name: "Frontend", Kind: TargetUsergroup, Mention: "<!subteam^S2>"}, ""},
+		{"backend", TargetAny, Target{ID: "C1", Name: "backend", Kind: TargetChannel, Mention: "<#C1>"}, ""},
+		{"backend team", TargetUser, Target{}, "I found no user called backend team"},
+		{"mobile", TargetAny, Target{}, "I found no user or channel called mobile"},
+	}
+
+	for _, s := range specs {
+		got, err := n.Resolve(s.in, s.kind)
+
+		var gotErr string
+		if err != nil {
+			gotErr = err.Error()
+		}
+
+		if gotErr != s.wantErr {
+			t.Errorf("expected error %q but got %q", s.wantErr, gotErr)
+		}
+
+		if got != s.want {
+			t.Error(cmp.Diff(s.want, got))
+		}
+	}
+
+	t.Run("no channel to mention in", func(t *testing.T) {
+		n.config.UsergroupChannel = ""
+
+		_, err := n.Resolve("frontend", TargetAny)
+		if err != (noUsergroupChannelError{name: "Frontend"}) {
+			t.Errorf("expected no usergroup channel error but got %v", err)
+		}
+	})
+}
+
+func TestEntityValues(t *testing.T) {
+	dir := newDirectory()
+	dir.SetUsers([]*slack.User{{Id: "U1", Name: "jfoster", Profile: &slack.ProfileInfo{RealName: "Jodie Foster"}}})
+	dir.SetChannels([]*slack.Channel{
+		{Id: "G1", Name: "team-private"},
+		{Id: "G2", Name: mpimName("mpdm-jfoster--tlevine-1", dir)},
+	})
+	dir.SetUsergroups([]slackUsergroup{
+		testUsergroup("S1", "backend-devs", "Backend Team"),
+		testUsergroup("S2", "Frontend", "frontend"),
+	})
+
+	conf := model.Config{
+		SnipsConfig: model.SnipsConfig{SlotName: "slack_names", ChannelSlotName: "slack_channels"},
+		SlackConfig: model.SlackConfig{ChannelAliases: map[string][]string{"frontend": {"web team"}}},
+	}
+
+	want := map[string][]string{
+		"slack_names": {"Jodie Foster"},
+		"slack_channels": {
+			"team-private", "team private",
+			"Jodie Foster and tlevine",
+			"Backend Team", "backend-devs", "backend devs",
+			"frontend", "web team",
+		},
+	}
+
+	if got := entityValues(conf, dir); !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestPingUsergroupMessage(t *testing.T) {
+	f, done := newFakeSlack(map[string][]string{"chat.postMessage": {`"ts": "1"`}})
+	defer done()
+
+	defer func(fn func() time.Time) { messageTime = fn }(messageTime)
+	messageTime = func() time.Time { return time.Date(2019, time.January, 11, 9, 30, 0, 0, time.Local) }
+
+	dir := newDirectory()
+	dir.SetChannels([]*slack.Channel{{Id: "C1", Name: "backend"}})
+	dir.SetUsergroups([]slackUsergroup{testUsergroup("S1", "backend-devs", "Backend Team", "C1")})
+
+	specs := map[string]string{
+		"{{.Mention}} morning {{.FirstName}}": "<!subteam^S1> morning Backend",
+		"standup this {{.Weekday}}":           "<!subteam^S1> standup this Friday",
+	}
+
+	for msg, want := range specs {
+		mc := buildTestClient(testMQTTClient{token: &testToken{}})
+		mc.config.SlackConfig.Messages = []string{msg}
+		mc.notifier = slackNotifier{config: mc.config.SlackConfig, dir: dir}
+
+		p := model.Payload{Slots: []model.Slot{{Name: "slack_names", Value: model.ValueType{Value: "backend team"}}}}
+		if _, err := pingAction(TargetAny)(mc, p, model.IntentConfig{}); err != nil {
+			t.Fatal(err)
+		}
+
+		reqs := f.requests["chat.postMessage"]
+		if got := reqs[len(reqs)-1].Get("text"); got != want {
+			t.Errorf("expected %q for %q but got %q", want, msg, got)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+// validateConfig loads the config at path applying the environment
+// overrides and validates it writing the problems found as json to w,
+// returns the process exit code
+func validateConfig(path string, w io.Writer) int {
+	errs := &model.ValidationError{Errors: []model.FieldError{}}
+
+	conf, err := model.LoadConfig(path)
+	if err == nil {
+		err = conf.ApplyEnv(os.LookupEnv)
+	}
+	if err == nil {
+		err = conf.Validate()
+	}
+
+	switch e := err.(type) {
+	case nil:
+	case *model.ValidationError:
+		errs = e
+	default:
+		errs.Errors = append(errs.Errors, model.FieldError{Message: err.Error()})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(errs); err != nil {
+		return 1
+	}
+
+	if len(errs.Errors) > 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jnormington/snips-slack-pinger/model"
+)
+
+func TestValidateConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssp-validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	valid := `{
+		"slack_config": {"token": "1234", "messages": ["standup!"]},
+		"snips_config": {"slack_intent": "slack-intent", "slot_name": "slack_names"},
+		"mqtt_config": {"host": ["localhost:1883"]}
+	}`
+
+	specs := []struct {
+		name     string
+		path     string
+		wantCode int
+		want     []model.FieldError
+	}{
+		{"valid", write("valid.json", valid), 0, []model.FieldError{}},
+		{"invalid", write("invalid.json", `{"mqtt_config": {"host": ["localhost:1883"]}}`), 1, []model.FieldError{
+			{Field: "slack_config.token", Message: "slack token required"},
+			{Field: "slack_config.messages", Message: "at least one slack message required"},
+			{Field: "snips_config.slack_intent", Message: "snips slack intent required"},
+			{Field: "snips_config.slot_name", Message: "snips slot name required"},
+		}},
+		{"unparsable", write("bad.json", `{`), 1, []model.FieldError{
+			{Message: "unexpected EOF"},
+		}},
+	}
+
+	for _, s := range specs {
+		t.Run(s.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if code := validateConfig(s.path, &buf); code != s.wantCode {
+				t.Errorf("expected exit code %d but got %d", s.wantCode, code)
+			}
+
+			var got model.ValidationError
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if !cmp.Equal(s.want, got.Errors) {
+				t.Error(cmp.Diff(s.want, got.Errors))
+			}
+		})
+	}
+	t.Run("environment overrides", func(t *testing.T) {
+		env := map[string]string{
+			"SSP_SLACK_CONFIG_TOKEN":        "1234",
+			"SSP_SLACK_CONFIG_MESSAGES":     `["standup!"]`,
+			"SSP_SNIPS_CONFIG_SLACK_INTENT": "slack-intent",
+			"SSP_SNIPS_CONFIG_SLOT_NAME":    "slack_names",
+		}
+
+		for k, v := range env {
+			os.Setenv(k, v)
+			defer os.Unsetenv(k)
+		}
+
+		var buf bytes.Buffer
+		path := write("env.json", `{"mqtt_config": {"host": ["localhost:1883"]}}`)
+		if code := validateConfig(path, &buf); code != 0 {
+			t.Errorf("expected exit code 0 but got %d: %s", code, buf.String())
+		}
+	})
+}